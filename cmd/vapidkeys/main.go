@@ -0,0 +1,20 @@
+// Command vapidkeys prints a new VAPID key pair for Web Push as .env lines:
+//
+//	go run ./cmd/vapidkeys >> .env
+//
+// The private key goes to stdout only; keep it out of logs and version control.
+package main
+
+import (
+	"fmt"
+	"log"
+	"nasa-app/internal/notification"
+)
+
+func main() {
+	public, private, err := notification.GenerateVAPIDKeys()
+	if err != nil {
+		log.Fatalf("generate VAPID keys: %v", err)
+	}
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\nVAPID_PRIVATE_KEY=%s\n", public, private)
+}
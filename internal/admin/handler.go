@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"errors"
+	"nasa-app/internal/airquality"
+	"nasa-app/internal/mlaudit"
+	"nasa-app/internal/mlclient"
+	"nasa-app/internal/notification"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Handler serves operator-only endpoints mounted under /admin.
+type Handler struct {
+	Shadow    *mlclient.Shadow
+	Drift     *mlaudit.DriftMonitor
+	AuditRepo *mlaudit.Repository
+	NotifRepo *notification.Repository
+}
+
+func NewHandler(shadow *mlclient.Shadow, drift *mlaudit.DriftMonitor, auditRepo *mlaudit.Repository, notifRepo *notification.Repository) *Handler {
+	return &Handler{Shadow: shadow, Drift: drift, AuditRepo: auditRepo, NotifRepo: notifRepo}
+}
+
+// ShadowReport returns the primary/candidate confusion matrix of the shadow ML model.
+func (h *Handler) ShadowReport(c *fiber.Ctx) error {
+	if h.Shadow == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shadow evaluation is not configured"})
+	}
+	return c.JSON(h.Shadow.Report())
+}
+
+// DriftReport returns the latest feature drift report. ?refresh=true recomputes it first.
+func (h *Handler) DriftReport(c *fiber.Ctx) error {
+	if h.Drift == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Drift monitoring is not configured"})
+	}
+
+	if c.QueryBool("refresh") {
+		report, err := h.Drift.Compute()
+		if errors.Is(err, mlaudit.ErrNotEnoughData) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error(), "report": report})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Drift computation failed"})
+		}
+		return c.JSON(report)
+	}
+
+	report, ok := h.Drift.Latest()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No drift report yet"})
+	}
+	return c.JSON(report)
+}
+
+// TrainingSet exports user feedback joined with the predictions it refers to.
+// ?format=csv|jsonl (default jsonl), ?since=RFC3339 limits the rows.
+func (h *Handler) TrainingSet(c *fiber.Ctx) error {
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "since must be an RFC3339 timestamp"})
+		}
+		since = t
+	}
+
+	rows, err := h.AuditRepo.TrainingSet(since)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	switch c.Query("format", "jsonl") {
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="training-set.csv"`)
+		return mlaudit.WriteCSV(c.Response().BodyWriter(), rows, airquality.FeatureNames)
+	case "jsonl":
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="training-set.jsonl"`)
+		return mlaudit.WriteJSONLines(c.Response().BodyWriter(), rows, airquality.FeatureNames)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be csv or jsonl"})
+	}
+}
+
+// Outbox lists notification outbox rows. ?status= (default dead), ?limit= (default 100, max 500).
+func (h *Handler) Outbox(c *fiber.Ctx) error {
+	status := c.Query("status", notification.OutboxDead)
+	statuses := []string{notification.OutboxPending, notification.OutboxSending, notification.OutboxSent, notification.OutboxDead, notification.OutboxCancelled}
+	if !slices.Contains(statuses, status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown status"})
+	}
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+
+	msgs, err := h.NotifRepo.ListOutbox(status, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(msgs)
+}
+
+// RequeueOutbox puts dead outbox rows back in the queue: one row with
+// /outbox/:id/requeue, or {"ids": [...]} (all dead rows when empty) with /outbox/requeue.
+func (h *Handler) RequeueOutbox(c *fiber.Ctx) error {
+	var ids []uint
+	if c.Params("id") != "" {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid outbox id"})
+		}
+		ids = []uint{uint(id)}
+	} else if len(c.Body()) > 0 {
+		var req struct {
+			IDs []uint `json:"ids"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+		ids = req.IDs
+	}
+
+	n, err := h.NotifRepo.RequeueOutbox(ids)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if n == 0 && len(ids) == 1 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No dead outbox message with this id"})
+	}
+	return c.JSON(fiber.Map{"requeued": n})
+}
@@ -0,0 +1,149 @@
+package airquality
+
+import (
+	"fmt"
+	"math"
+)
+
+// AQI standards a subscription can choose from.
+const (
+	StandardUSEPA = "us_epa" // US EPA AQI, 0-500
+	StandardEU    = "eu"     // European Air Quality Index (2024 bands), 1 (good) - 6 (extremely poor)
+)
+
+// Standards lists the supported AQI standards.
+var Standards = []string{StandardUSEPA, StandardEU}
+
+// breakpoint maps a concentration range to an index range.
+type breakpoint struct {
+	cLow, cHigh float64
+	iLow, iHigh float64
+}
+
+// aqiScale is the breakpoint table of one pollutant under one standard.
+type aqiScale struct {
+	feature     string                     // name in Features
+	convert     func(ugm3 float64) float64 // µg/m³ -> unit of the table
+	precision   float64                    // concentrations are truncated to this step
+	breakpoints []breakpoint
+}
+
+func identity(v float64) float64 { return v }
+
+// usEPAScales follow the EPA 2024 breakpoints. Gases are converted from µg/m³
+// at 25 °C; hourly values are used for every pollutant since that is what the
+// upstream API returns.
+var usEPAScales = []aqiScale{
+	{feature: "pm2_5", convert: identity, precision: 0.1, breakpoints: []breakpoint{
+		{0, 9.0, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500},
+	}},
+	{feature: "pm10", convert: identity, precision: 1, breakpoints: []breakpoint{
+		{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+		{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500},
+	}},
+	{feature: "no2", convert: func(v float64) float64 { return v / 1.88 }, precision: 1, breakpoints: []breakpoint{
+		{0, 53, 0, 50}, {54, 100, 51, 100}, {101, 360, 101, 150},
+		{361, 649, 151, 200}, {650, 1249, 201, 300}, {1250, 2049, 301, 500},
+	}},
+	{feature: "so2", convert: func(v float64) float64 { return v / 2.62 }, precision: 1, breakpoints: []breakpoint{
+		{0, 35, 0, 50}, {36, 75, 51, 100}, {76, 185, 101, 150},
+		{186, 304, 151, 200}, {305, 604, 201, 300}, {605, 1004, 301, 500},
+	}},
+	{feature: "co", convert: func(v float64) float64 { return v / 1145 }, precision: 0.1, breakpoints: []breakpoint{
+		{0, 4.4, 0, 50}, {4.5, 9.4, 51, 100}, {9.5, 12.4, 101, 150},
+		{12.5, 15.4, 151, 200}, {15.5, 30.4, 201, 300}, {30.5, 50.4, 301, 500},
+	}},
+}
+
+// euScales are the hourly band limits (µg/m³) of the EEA's European Air
+// Quality Index as revised in 2024 to follow the 2021 WHO guidelines. A value
+// on a limit belongs to the lower band. The EAQI has no CO band.
+var euScales = []aqiScale{
+	{feature: "pm2_5", convert: identity, breakpoints: euBands(5, 15, 50, 90, 140)},
+	{feature: "pm10", convert: identity, breakpoints: euBands(15, 45, 120, 195, 270)},
+	{feature: "no2", convert: identity, breakpoints: euBands(10, 25, 60, 100, 150)},
+	{feature: "so2", convert: identity, breakpoints: euBands(20, 40, 125, 190, 275)},
+}
+
+// euBands builds bands 1..6 from the upper limits of bands 1..5.
+func euBands(limits ...float64) []breakpoint {
+	out := make([]breakpoint, 0, len(limits)+1)
+	low := 0.0
+	for i, high := range limits {
+		out = append(out, breakpoint{cLow: low, cHigh: high, iLow: float64(i + 1), iHigh: float64(i + 1)})
+		low = high
+	}
+	band := float64(len(limits) + 1)
+	return append(out, breakpoint{cLow: low, cHigh: math.Inf(1), iLow: band, iHigh: band})
+}
+
+// AQIResult is the index of a reading and the pollutant that set it.
+type AQIResult struct {
+	Standard string `json:"standard"`
+	Value    int    `json:"value"`
+	Dominant string `json:"dominant"` // feature name, e.g. "pm2_5"
+	Category string `json:"category"`
+}
+
+// AQI computes the index of metrics under standard: the highest sub-index of
+// all pollutants the standard covers.
+func AQI(standard string, metrics Metrics) (AQIResult, error) {
+	var scales []aqiScale
+	switch standard {
+	case StandardUSEPA, "":
+		standard, scales = StandardUSEPA, usEPAScales
+	case StandardEU:
+		scales = euScales
+	default:
+		return AQIResult{}, fmt.Errorf("unknown AQI standard %q", standard)
+	}
+
+	values := metrics.FeatureMap()
+	res := AQIResult{Standard: standard}
+	for _, s := range scales {
+		sub := s.index(values[s.feature])
+		if sub > res.Value || res.Dominant == "" {
+			res.Value, res.Dominant = sub, s.feature
+		}
+	}
+	res.Category = category(standard, res.Value)
+	return res, nil
+}
+
+func (s aqiScale) index(ugm3 float64) int {
+	c := s.convert(math.Max(ugm3, 0))
+	if s.precision > 0 {
+		// 9.1/0.1 = 90.99999...; küçük pay olmadan kesme bir adım aşağı düşer
+		c = math.Floor(c/s.precision+1e-6) * s.precision
+	}
+	for _, bp := range s.breakpoints {
+		// Tablo aralıkları arasındaki boşluklar kesme ile kapanır; yine de üst sınıra göre ara
+		if c <= bp.cHigh+s.precision/2 {
+			if bp.cHigh == bp.cLow || math.IsInf(bp.cHigh, 1) {
+				return int(bp.iLow)
+			}
+			v := (bp.iHigh-bp.iLow)/(bp.cHigh-bp.cLow)*(math.Max(c, bp.cLow)-bp.cLow) + bp.iLow
+			return int(math.Round(v))
+		}
+	}
+	// Tablonun üstü: en yüksek değerde sabitle
+	return int(s.breakpoints[len(s.breakpoints)-1].iHigh)
+}
+
+var (
+	usEPACategories = []string{"good", "moderate", "unhealthy_for_sensitive_groups", "unhealthy", "very_unhealthy", "hazardous"}
+	euCategories    = []string{"good", "fair", "moderate", "poor", "very_poor", "extremely_poor"}
+)
+
+func category(standard string, value int) string {
+	if standard == StandardEU {
+		return euCategories[min(max(value, 1), len(euCategories))-1]
+	}
+	for i, upper := range []int{50, 100, 150, 200, 300} {
+		if value <= upper {
+			return usEPACategories[i]
+		}
+	}
+	return usEPACategories[len(usEPACategories)-1]
+}
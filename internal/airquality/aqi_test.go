@@ -0,0 +1,185 @@
+package airquality
+
+import "testing"
+
+// reading returns metrics with only the given pollutant (µg/m³) set.
+func reading(feature string, ugm3 float64) Metrics {
+	var m Metrics
+	switch feature {
+	case "pm2_5":
+		m.PM25 = ugm3
+	case "pm10":
+		m.PM10 = ugm3
+	case "no2":
+		m.NO2 = ugm3
+	case "so2":
+		m.SO2 = ugm3
+	case "co":
+		m.CO = ugm3
+	}
+	return m
+}
+
+// EPA gas tables are in ppb (ppm for CO); the API reports µg/m³ at 25 °C.
+func no2ppb(v float64) float64 { return v * 1.88 }
+func so2ppb(v float64) float64 { return v * 2.62 }
+func coppm(v float64) float64  { return v * 1145 }
+
+type aqiCase struct {
+	feature  string
+	ugm3     float64
+	value    int
+	category string
+}
+
+func TestAQIUSEPABoundaries(t *testing.T) {
+	tests := []aqiCase{
+		{"pm2_5", 0, 0, "good"},
+		{"pm2_5", 9.0, 50, "good"},
+		{"pm2_5", 9.09, 50, "good"}, // truncated to 9.0
+		{"pm2_5", 9.1, 51, "moderate"},
+		{"pm2_5", 35.4, 100, "moderate"},
+		{"pm2_5", 35.5, 101, "unhealthy_for_sensitive_groups"},
+		{"pm2_5", 55.4, 150, "unhealthy_for_sensitive_groups"},
+		{"pm2_5", 55.5, 151, "unhealthy"},
+		{"pm2_5", 125.4, 200, "unhealthy"},
+		{"pm2_5", 125.5, 201, "very_unhealthy"},
+		{"pm2_5", 225.4, 300, "very_unhealthy"},
+		{"pm2_5", 225.5, 301, "hazardous"},
+		{"pm2_5", 325.4, 500, "hazardous"},
+		{"pm2_5", 1000, 500, "hazardous"},
+
+		{"pm10", 54, 50, "good"},
+		{"pm10", 54.9, 50, "good"},
+		{"pm10", 55, 51, "moderate"},
+		{"pm10", 154, 100, "moderate"},
+		{"pm10", 155, 101, "unhealthy_for_sensitive_groups"},
+		{"pm10", 254, 150, "unhealthy_for_sensitive_groups"},
+		{"pm10", 255, 151, "unhealthy"},
+		{"pm10", 354, 200, "unhealthy"},
+		{"pm10", 355, 201, "very_unhealthy"},
+		{"pm10", 424, 300, "very_unhealthy"},
+		{"pm10", 425, 301, "hazardous"},
+		{"pm10", 604, 500, "hazardous"},
+
+		{"no2", no2ppb(53), 50, "good"},
+		{"no2", no2ppb(54), 51, "moderate"},
+		{"no2", no2ppb(100), 100, "moderate"},
+		{"no2", no2ppb(101), 101, "unhealthy_for_sensitive_groups"},
+		{"no2", no2ppb(360), 150, "unhealthy_for_sensitive_groups"},
+		{"no2", no2ppb(361), 151, "unhealthy"},
+		{"no2", no2ppb(649), 200, "unhealthy"},
+		{"no2", no2ppb(650), 201, "very_unhealthy"},
+		{"no2", no2ppb(1249), 300, "very_unhealthy"},
+		{"no2", no2ppb(1250), 301, "hazardous"},
+		{"no2", no2ppb(2049), 500, "hazardous"},
+
+		{"so2", so2ppb(35), 50, "good"},
+		{"so2", so2ppb(36), 51, "moderate"},
+		{"so2", so2ppb(75), 100, "moderate"},
+		{"so2", so2ppb(76), 101, "unhealthy_for_sensitive_groups"},
+		{"so2", so2ppb(185), 150, "unhealthy_for_sensitive_groups"},
+		{"so2", so2ppb(186), 151, "unhealthy"},
+		{"so2", so2ppb(304), 200, "unhealthy"},
+		{"so2", so2ppb(305), 201, "very_unhealthy"},
+		{"so2", so2ppb(604), 300, "very_unhealthy"},
+		{"so2", so2ppb(605), 301, "hazardous"},
+		{"so2", so2ppb(1004), 500, "hazardous"},
+
+		{"co", coppm(4.4), 50, "good"},
+		{"co", coppm(4.5), 51, "moderate"},
+		{"co", coppm(9.4), 100, "moderate"},
+		{"co", coppm(9.5), 101, "unhealthy_for_sensitive_groups"},
+		{"co", coppm(12.4), 150, "unhealthy_for_sensitive_groups"},
+		{"co", coppm(12.5), 151, "unhealthy"},
+		{"co", coppm(15.4), 200, "unhealthy"},
+		{"co", coppm(15.5), 201, "very_unhealthy"},
+		{"co", coppm(30.4), 300, "very_unhealthy"},
+		{"co", coppm(30.5), 301, "hazardous"},
+		{"co", coppm(50.4), 500, "hazardous"},
+	}
+	checkAQI(t, StandardUSEPA, tests)
+}
+
+func TestAQIEUBoundaries(t *testing.T) {
+	// 2024 EAQI bands; a value on a limit is in the lower band
+	tests := []aqiCase{
+		{"pm2_5", 0, 1, "good"},
+		{"pm2_5", 5, 1, "good"},
+		{"pm2_5", 5.1, 2, "fair"},
+		{"pm2_5", 15, 2, "fair"},
+		{"pm2_5", 15.1, 3, "moderate"},
+		{"pm2_5", 50, 3, "moderate"},
+		{"pm2_5", 50.1, 4, "poor"},
+		{"pm2_5", 90, 4, "poor"},
+		{"pm2_5", 90.1, 5, "very_poor"},
+		{"pm2_5", 140, 5, "very_poor"},
+		{"pm2_5", 140.1, 6, "extremely_poor"},
+
+		{"pm10", 15, 1, "good"},
+		{"pm10", 16, 2, "fair"},
+		{"pm10", 45, 2, "fair"},
+		{"pm10", 46, 3, "moderate"},
+		{"pm10", 120, 3, "moderate"},
+		{"pm10", 121, 4, "poor"},
+		{"pm10", 195, 4, "poor"},
+		{"pm10", 196, 5, "very_poor"},
+		{"pm10", 270, 5, "very_poor"},
+		{"pm10", 271, 6, "extremely_poor"},
+
+		// EU limits are in µg/m³, no conversion
+		{"no2", 10, 1, "good"},
+		{"no2", 11, 2, "fair"},
+		{"no2", 25, 2, "fair"},
+		{"no2", 26, 3, "moderate"},
+		{"no2", 60, 3, "moderate"},
+		{"no2", 61, 4, "poor"},
+		{"no2", 100, 4, "poor"},
+		{"no2", 101, 5, "very_poor"},
+		{"no2", 150, 5, "very_poor"},
+		{"no2", 151, 6, "extremely_poor"},
+
+		{"so2", 20, 1, "good"},
+		{"so2", 21, 2, "fair"},
+		{"so2", 40, 2, "fair"},
+		{"so2", 41, 3, "moderate"},
+		{"so2", 125, 3, "moderate"},
+		{"so2", 126, 4, "poor"},
+		{"so2", 190, 4, "poor"},
+		{"so2", 191, 5, "very_poor"},
+		{"so2", 275, 5, "very_poor"},
+		{"so2", 276, 6, "extremely_poor"},
+
+		{"co", 100000, 1, "good"}, // the EAQI has no CO band
+	}
+	checkAQI(t, StandardEU, tests)
+}
+
+func checkAQI(t *testing.T, standard string, tests []aqiCase) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := AQI(standard, reading(tt.feature, tt.ugm3))
+		if err != nil {
+			t.Fatalf("AQI(%s): %v", standard, err)
+		}
+		if got.Value != tt.value || got.Category != tt.category {
+			t.Errorf("%s %s %g µg/m³: got %d %s, want %d %s", standard, tt.feature, tt.ugm3, got.Value, got.Category, tt.value, tt.category)
+		}
+		if tt.feature != "co" && got.Value > 1 && got.Dominant != tt.feature {
+			t.Errorf("%s %s %g µg/m³: dominant %s", standard, tt.feature, tt.ugm3, got.Dominant)
+		}
+	}
+}
+
+func TestAQIDominantAndStandard(t *testing.T) {
+	got, err := AQI("", Metrics{PM25: 9.0, PM10: 155, NO2: no2ppb(20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Standard != StandardUSEPA || got.Value != 101 || got.Dominant != "pm10" {
+		t.Errorf("got %+v, want us_epa 101 dominated by pm10", got)
+	}
+	if _, err := AQI("who", Metrics{}); err == nil {
+		t.Error("unknown standard accepted")
+	}
+}
@@ -0,0 +1,257 @@
+package airquality
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// MaxForecastHours is the longest risk timeline we serve.
+	MaxForecastHours  = 48
+	openMeteoHourTime = "2006-01-02T15:04"
+)
+
+// HourlyMetrics is the forecast for a single hour.
+type HourlyMetrics struct {
+	Time    time.Time `json:"time"`
+	Metrics Metrics   `json:"metrics"`
+}
+
+// GetHourlyForecast fetches pollutant and weather forecasts for the next hours,
+// starting at the current hour. Hours with missing values are skipped.
+func (s *Service) GetHourlyForecast(latitude, longitude float64, hours int) ([]HourlyMetrics, error) {
+	if hours <= 0 || hours > MaxForecastHours {
+		hours = MaxForecastHours
+	}
+
+	airQualityURL := fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=carbon_monoxide,sulphur_dioxide,nitrogen_dioxide,pm10,pm2_5&forecast_hours=%d&timezone=UTC", s.airQualityURL, latitude, longitude, hours)
+
+	var airQualityPayload struct {
+		Hourly struct {
+			Time            []string   `json:"time"`
+			PM25            []*float64 `json:"pm2_5"`
+			PM10            []*float64 `json:"pm10"`
+			NitrogenDioxide []*float64 `json:"nitrogen_dioxide"`
+			SulphurDioxide  []*float64 `json:"sulphur_dioxide"`
+			CarbonMonoxide  []*float64 `json:"carbon_monoxide"`
+		} `json:"hourly"`
+	}
+	if err := s.getJSON(airQualityURL, "air quality", &airQualityPayload); err != nil {
+		return nil, err
+	}
+
+	weatherURL := fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=temperature_2m,relative_humidity_2m&forecast_hours=%d&timezone=UTC", s.weatherForecastURL, latitude, longitude, hours)
+
+	var weatherPayload struct {
+		Hourly struct {
+			Time        []string   `json:"time"`
+			Temperature []*float64 `json:"temperature_2m"`
+			Humidity    []*float64 `json:"relative_humidity_2m"`
+		} `json:"hourly"`
+	}
+	if err := s.getJSON(weatherURL, "weather", &weatherPayload); err != nil {
+		return nil, err
+	}
+
+	// İki API aynı saatleri döndürmeyebilir; hava durumunu saate göre eşleştir
+	type weatherHour struct{ temperature, humidity *float64 }
+	weather := make(map[string]weatherHour, len(weatherPayload.Hourly.Time))
+	for i, t := range weatherPayload.Hourly.Time {
+		weather[t] = weatherHour{
+			temperature: at(weatherPayload.Hourly.Temperature, i),
+			humidity:    at(weatherPayload.Hourly.Humidity, i),
+		}
+	}
+
+	aq := airQualityPayload.Hourly
+	out := make([]HourlyMetrics, 0, len(aq.Time))
+	for i, t := range aq.Time {
+		ts, err := time.Parse(openMeteoHourTime, t)
+		if err != nil {
+			return nil, fmt.Errorf("parse forecast time %q: %w", t, err)
+		}
+
+		w := weather[t]
+		values := []*float64{w.temperature, w.humidity, at(aq.PM25, i), at(aq.PM10, i), at(aq.NitrogenDioxide, i), at(aq.SulphurDioxide, i), at(aq.CarbonMonoxide, i)}
+		complete := true
+		for _, v := range values {
+			if v == nil {
+				complete = false
+				break
+			}
+		}
+		if !complete {
+			continue
+		}
+
+		out = append(out, HourlyMetrics{
+			Time: ts.UTC(),
+			Metrics: Metrics{
+				Temperature:       *w.temperature,
+				Humidity:          *w.humidity,
+				PM25:              *aq.PM25[i],
+				PM10:              *aq.PM10[i],
+				NO2:               *aq.NitrogenDioxide[i],
+				SO2:               *aq.SulphurDioxide[i],
+				CO:                *aq.CarbonMonoxide[i],
+				PopulationDensity: defaultPopulationDensity,
+			},
+		})
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("forecast response has no complete hours")
+	}
+	return out, nil
+}
+
+func at(values []*float64, i int) *float64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// ForecastPredictor predicts the risk of several snapshots of one location in a single (batched) call.
+type ForecastPredictor func(latitude, longitude float64, metrics []Metrics) ([]Prediction, error)
+
+// TimelinePoint is the predicted risk for one forecast hour.
+type TimelinePoint struct {
+	Time       time.Time `json:"time"`
+	Metrics    Metrics   `json:"metrics"`
+	RiskLevel  string    `json:"risk_level"`
+	Confidence *float64  `json:"confidence,omitempty"`
+}
+
+// Timeline is the hourly risk forecast for a location.
+type Timeline struct {
+	Latitude     float64         `json:"latitude"`
+	Longitude    float64         `json:"longitude"`
+	ModelRun     time.Time       `json:"model_run"`
+	ModelVersion string          `json:"model_version,omitempty"`
+	Points       []TimelinePoint `json:"points"`
+}
+
+// Forecaster builds risk timelines and caches them per location and model run.
+// A model run is a fixed window (runInterval) during which the upstream
+// forecast is treated as unchanged. Concurrent requests for the same location
+// share one build; timelines with hours the model couldn't predict are served
+// but not cached, so the next request tries again.
+type Forecaster struct {
+	service     *Service
+	predict     ForecastPredictor
+	runInterval time.Duration
+	flight      singleflight.Group
+
+	mu    sync.Mutex
+	run   time.Time
+	cache map[string]Timeline
+}
+
+func NewForecaster(service *Service, predict ForecastPredictor, runInterval time.Duration) *Forecaster {
+	if runInterval <= 0 {
+		runInterval = time.Hour
+	}
+	return &Forecaster{
+		service:     service,
+		predict:     predict,
+		runInterval: runInterval,
+		cache:       map[string]Timeline{},
+	}
+}
+
+// Timeline returns the risk timeline for the next hours at the given location.
+func (f *Forecaster) Timeline(latitude, longitude float64, hours int) (Timeline, error) {
+	if hours <= 0 || hours > MaxForecastHours {
+		hours = MaxForecastHours
+	}
+
+	run := time.Now().UTC().Truncate(f.runInterval)
+	// ~1 km hassasiyet; yakın konumlar aynı cache kaydını paylaşır
+	key := fmt.Sprintf("%.2f,%.2f", latitude, longitude)
+
+	f.mu.Lock()
+	if !run.Equal(f.run) {
+		f.run = run
+		f.cache = map[string]Timeline{}
+	}
+	timeline, ok := f.cache[key]
+	f.mu.Unlock()
+
+	if !ok {
+		// Aynı konum için eşzamanlı istekler tek bir build'i bekler
+		v, err, _ := f.flight.Do(key, func() (any, error) {
+			timeline, complete, err := f.build(latitude, longitude, run)
+			if err != nil {
+				return Timeline{}, err
+			}
+			if complete {
+				f.mu.Lock()
+				if run.Equal(f.run) {
+					f.cache[key] = timeline
+				}
+				f.mu.Unlock()
+			}
+			return timeline, nil
+		})
+		if err != nil {
+			return Timeline{}, err
+		}
+		timeline = v.(Timeline)
+	}
+
+	if len(timeline.Points) > hours {
+		timeline.Points = timeline.Points[:hours]
+	}
+	return timeline, nil
+}
+
+// build fetches the forecast and predicts every hour. complete is false when
+// some hour has no prediction (its risk level is "unknown").
+func (f *Forecaster) build(latitude, longitude float64, run time.Time) (timeline Timeline, complete bool, err error) {
+	hourly, err := f.service.GetHourlyForecast(latitude, longitude, MaxForecastHours)
+	if err != nil {
+		return Timeline{}, false, err
+	}
+
+	metrics := make([]Metrics, len(hourly))
+	for i, h := range hourly {
+		metrics[i] = h.Metrics
+	}
+
+	predictions, err := f.predict(latitude, longitude, metrics)
+	if err != nil {
+		return Timeline{}, false, fmt.Errorf("forecast prediction: %w", err)
+	}
+	if len(predictions) != len(hourly) {
+		return Timeline{}, false, fmt.Errorf("forecast prediction returned %d results for %d hours", len(predictions), len(hourly))
+	}
+
+	complete = true
+	timeline = Timeline{
+		Latitude:  latitude,
+		Longitude: longitude,
+		ModelRun:  run,
+		Points:    make([]TimelinePoint, len(hourly)),
+	}
+	for i, h := range hourly {
+		p := predictions[i]
+		if p.RiskLevel == "" || p.RiskLevel == "unknown" {
+			p.RiskLevel, complete = "unknown", false
+		}
+		if timeline.ModelVersion == "" {
+			timeline.ModelVersion = p.ModelVersion
+		}
+		timeline.Points[i] = TimelinePoint{
+			Time:       h.Time,
+			Metrics:    h.Metrics,
+			RiskLevel:  p.RiskLevel,
+			Confidence: p.Confidence,
+		}
+	}
+	return timeline, complete, nil
+}
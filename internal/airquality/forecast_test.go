@@ -0,0 +1,115 @@
+package airquality
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newForecastServer serves a three-hour Open-Meteo air quality and weather forecast.
+func newForecastServer(t *testing.T) *Service {
+	t.Helper()
+	start := time.Now().UTC().Truncate(time.Hour)
+	hours := make([]string, 3)
+	for i := range hours {
+		hours[i] = `"` + start.Add(time.Duration(i)*time.Hour).Format(openMeteoHourTime) + `"`
+	}
+	times := strings.Join(hours, ",")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/weather" {
+			fmt.Fprintf(w, `{"hourly":{"time":[%s],"temperature_2m":[20,21,22],"relative_humidity_2m":[50,50,50]}}`, times)
+			return
+		}
+		fmt.Fprintf(w, `{"hourly":{"time":[%s],"pm2_5":[10,20,30],"pm10":[20,30,40],"nitrogen_dioxide":[5,5,5],"sulphur_dioxide":[1,1,1],"carbon_monoxide":[200,200,200]}}`, times)
+	}))
+	t.Cleanup(srv.Close)
+	return &Service{client: srv.Client(), airQualityURL: srv.URL + "/air-quality", weatherForecastURL: srv.URL + "/weather"}
+}
+
+func TestTimelineSkipsCacheWithoutPredictions(t *testing.T) {
+	var calls atomic.Int32
+	f := NewForecaster(newForecastServer(t), func(_, _ float64, metrics []Metrics) ([]Prediction, error) {
+		calls.Add(1)
+		out := make([]Prediction, len(metrics))
+		for i := range out {
+			out[i].RiskLevel = "low"
+		}
+		if calls.Load() == 1 {
+			out[1] = Prediction{RiskLevel: "unknown"} // model o saat için cevap vermedi
+		}
+		return out, nil
+	}, time.Hour)
+
+	first, err := f.Timeline(41, 29, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := first.Points[1].RiskLevel; got != "unknown" {
+		t.Fatalf("first timeline hour 1 = %q, want unknown", got)
+	}
+
+	second, err := f.Timeline(41, 29, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Points[1].RiskLevel; got != "low" {
+		t.Errorf("second timeline hour 1 = %q, want low (incomplete timeline must not be cached)", got)
+	}
+
+	if _, err := f.Timeline(41, 29, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("predict called %d times, want 2 (complete timeline is cached)", got)
+	}
+}
+
+func TestTimelineSharesConcurrentBuilds(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	f := NewForecaster(newForecastServer(t), func(_, _ float64, metrics []Metrics) ([]Prediction, error) {
+		calls.Add(1)
+		<-release
+		out := make([]Prediction, len(metrics))
+		for i := range out {
+			out[i].RiskLevel = "low"
+		}
+		return out, nil
+	}, time.Hour)
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.Timeline(41, 29, 0)
+			errs <- err
+		}()
+	}
+	// İlk build tahmin aşamasına gelene kadar bekle, diğerleri ona katılsın
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("predict called %d times for %d concurrent requests, want 1", got, callers)
+	}
+}
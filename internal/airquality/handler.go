@@ -6,35 +6,69 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// MLPredictor is a function type for ML predictions
-type MLPredictor func(latitude, longitude float64, metrics Metrics) (string, error)
+// MLPredictor is a function type for ML predictions. With explain set the
+// prediction also carries the pollutants that drove it.
+type MLPredictor func(latitude, longitude float64, metrics Metrics, explain bool) (Prediction, error)
+
+// Prediction is the ML result for a location together with model metadata.
+type Prediction struct {
+	ID            uint // audit log ID, referenced by feedback; 0 if not recorded
+	RiskLevel     string
+	Confidence    *float64 // probability of RiskLevel, nil when the model does not report it
+	Probabilities map[string]float64
+	ModelVersion  string
+	SchemaVersion string
+	Drivers       []Driver
+}
+
+// Driver is a pollutant that pushed the prediction towards its risk level.
+type Driver struct {
+	Pollutant    string  `json:"pollutant"`
+	Contribution float64 `json:"contribution"`
+}
 
 type Handler struct {
 	Service     *Service
 	MLPredictor MLPredictor
+	Forecaster  *Forecaster
 }
 
 type GetAirQualityRequest struct {
 	Latitude  float64 `json:"latitude" query:"latitude"`
 	Longitude float64 `json:"longitude" query:"longitude"`
+	Explain   bool    `json:"explain" query:"explain"`
 }
 
 type AirQualityResponse struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Metrics   Metrics `json:"metrics"`
-	RiskLevel string  `json:"risk_level"`
-	Timestamp string  `json:"timestamp"`
+	Latitude      float64            `json:"latitude"`
+	Longitude     float64            `json:"longitude"`
+	Metrics       Metrics            `json:"metrics"`
+	PredictionID  uint               `json:"prediction_id,omitempty"`
+	RiskLevel     string             `json:"risk_level"`
+	Confidence    *float64           `json:"confidence,omitempty"`
+	Probabilities map[string]float64 `json:"probabilities,omitempty"`
+	ModelVersion  string             `json:"model_version,omitempty"`
+	SchemaVersion string             `json:"feature_schema_version,omitempty"`
+	Explanation   []Driver           `json:"explanation,omitempty"`
+	Timestamp     string             `json:"timestamp"`
 }
 
-func NewHandler(service *Service, mlPredictor MLPredictor) *Handler {
+type GetForecastRequest struct {
+	Latitude  float64 `query:"latitude"`
+	Longitude float64 `query:"longitude"`
+	Hours     int     `query:"hours"`
+}
+
+func NewHandler(service *Service, mlPredictor MLPredictor, forecaster *Forecaster) *Handler {
 	return &Handler{
 		Service:     service,
 		MLPredictor: mlPredictor,
+		Forecaster:  forecaster,
 	}
 }
 
-// GetAirQuality fetches current air quality data and ML prediction for a location
+// GetAirQuality fetches current air quality data and ML prediction for a location.
+// ?explain=true adds the top contributing pollutants.
 func (h *Handler) GetAirQuality(c *fiber.Ctx) error {
 	var req GetAirQualityRequest
 
@@ -61,21 +95,52 @@ func (h *Handler) GetAirQuality(c *fiber.Ctx) error {
 	}
 
 	// Get ML prediction
-	riskLevel := "unknown"
+	prediction := Prediction{RiskLevel: "unknown"}
 	if h.MLPredictor != nil {
-		predictedRisk, err := h.MLPredictor(req.Latitude, req.Longitude, metrics)
-		if err == nil && predictedRisk != "" {
-			riskLevel = predictedRisk
+		predicted, err := h.MLPredictor(req.Latitude, req.Longitude, metrics, req.Explain)
+		if err == nil && predicted.RiskLevel != "" {
+			prediction = predicted
 		}
 	}
 
 	response := AirQualityResponse{
-		Latitude:  req.Latitude,
-		Longitude: req.Longitude,
-		Metrics:   metrics,
-		RiskLevel: riskLevel,
-		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05Z07:00"),
+		Latitude:      req.Latitude,
+		Longitude:     req.Longitude,
+		Metrics:       metrics,
+		PredictionID:  prediction.ID,
+		RiskLevel:     prediction.RiskLevel,
+		Confidence:    prediction.Confidence,
+		Probabilities: prediction.Probabilities,
+		ModelVersion:  prediction.ModelVersion,
+		SchemaVersion: prediction.SchemaVersion,
+		Explanation:   prediction.Drivers,
+		Timestamp:     time.Now().UTC().Format("2006-01-02T15:04:05Z07:00"),
 	}
 
 	return c.JSON(response)
 }
+
+// GetForecast returns the hourly risk timeline for the next hours (default and max 48).
+func (h *Handler) GetForecast(c *fiber.Ctx) error {
+	var req GetForecastRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid query parameters",
+		})
+	}
+
+	if req.Latitude == 0 || req.Longitude == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Latitude and longitude are required",
+		})
+	}
+
+	timeline, err := h.Forecaster.Timeline(req.Latitude, req.Longitude, req.Hours)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build risk forecast",
+		})
+	}
+
+	return c.JSON(timeline)
+}
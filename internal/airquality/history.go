@@ -0,0 +1,76 @@
+package airquality
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PollutantStats summarises one pollutant over a period (µg/m³).
+type PollutantStats struct {
+	Feature string  `json:"feature"`
+	Label   string  `json:"label"`
+	Min     float64 `json:"min"`
+	Mean    float64 `json:"mean"`
+	Max     float64 `json:"max"`
+	Samples int     `json:"samples"`
+}
+
+// GetPollutantStats returns min, mean and max of every pollutant in
+// Pollutants for the local days from..to (inclusive). The days are taken in
+// from's location, so "yesterday" matches the user's calendar.
+func (s *Service) GetPollutantStats(latitude, longitude float64, from, to time.Time) ([]PollutantStats, error) {
+	loc := from.Location()
+	airQualityURL := fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=carbon_monoxide,sulphur_dioxide,nitrogen_dioxide,pm10,pm2_5&start_date=%s&end_date=%s&timezone=%s",
+		s.airQualityURL, latitude, longitude, from.Format(time.DateOnly), to.In(loc).Format(time.DateOnly), url.QueryEscape(loc.String()))
+
+	var payload struct {
+		Hourly struct {
+			PM25            []*float64 `json:"pm2_5"`
+			PM10            []*float64 `json:"pm10"`
+			NitrogenDioxide []*float64 `json:"nitrogen_dioxide"`
+			SulphurDioxide  []*float64 `json:"sulphur_dioxide"`
+			CarbonMonoxide  []*float64 `json:"carbon_monoxide"`
+		} `json:"hourly"`
+	}
+	if err := s.getJSON(airQualityURL, "air quality history", &payload); err != nil {
+		return nil, err
+	}
+
+	series := map[string][]*float64{
+		"pm2_5": payload.Hourly.PM25,
+		"pm10":  payload.Hourly.PM10,
+		"no2":   payload.Hourly.NitrogenDioxide,
+		"so2":   payload.Hourly.SulphurDioxide,
+		"co":    payload.Hourly.CarbonMonoxide,
+	}
+
+	out := make([]PollutantStats, 0, len(Pollutants))
+	for _, p := range Pollutants {
+		st := PollutantStats{Feature: p.Feature, Label: p.Label}
+		sum := 0.0
+		for _, v := range series[p.Feature] {
+			if v == nil {
+				continue
+			}
+			if st.Samples == 0 || *v < st.Min {
+				st.Min = *v
+			}
+			if st.Samples == 0 || *v > st.Max {
+				st.Max = *v
+			}
+			sum += *v
+			st.Samples++
+		}
+		if st.Samples > 0 {
+			st.Mean = sum / float64(st.Samples)
+			out = append(out, st)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("air quality history has no values")
+	}
+	return out, nil
+}
@@ -12,6 +12,8 @@ import (
 const (
 	airQualityBaseURL = "https://air-quality-api.open-meteo.com/v1/air-quality"
 	weatherBaseURL    = "https://api.open-meteo.com/v1/forecast"
+
+	defaultPopulationDensity = 497
 )
 
 // Service retrieves AQI data from Open-Meteo APIs.
@@ -53,25 +55,6 @@ func (s *Service) GetMetrics(latitude, longitude float64) (Metrics, error) {
 	// Fetch air quality data (pollutants)
 	airQualityURL := fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=carbon_monoxide,sulphur_dioxide,nitrogen_dioxide,pm10,pm2_5&timezone=UTC", s.airQualityURL, latitude, longitude)
 
-	req, err := http.NewRequest(http.MethodGet, airQualityURL, nil)
-	if err != nil {
-		return Metrics{}, fmt.Errorf("create air quality request: %w", err)
-	}
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return Metrics{}, fmt.Errorf("air quality request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return Metrics{}, fmt.Errorf("air quality request failed: status %d, url: %s, body read error: %v", resp.StatusCode, airQualityURL, readErr)
-		}
-		return Metrics{}, fmt.Errorf("air quality request failed: status %d, url: %s, response: %s", resp.StatusCode, airQualityURL, string(bodyBytes))
-	}
-
 	var airQualityPayload struct {
 		Hourly struct {
 			PM25            []float64 `json:"pm2_5"`
@@ -81,40 +64,21 @@ func (s *Service) GetMetrics(latitude, longitude float64) (Metrics, error) {
 			CarbonMonoxide  []float64 `json:"carbon_monoxide"`
 		} `json:"hourly"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&airQualityPayload); err != nil {
-		return Metrics{}, fmt.Errorf("decode air quality response: %w", err)
+	if err := s.getJSON(airQualityURL, "air quality", &airQualityPayload); err != nil {
+		return Metrics{}, err
 	}
 
 	// Fetch weather data (temperature and humidity)
 	weatherURL := fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=temperature_2m,relative_humidity_2m&timezone=UTC", s.weatherForecastURL, latitude, longitude)
 
-	req, err = http.NewRequest(http.MethodGet, weatherURL, nil)
-	if err != nil {
-		return Metrics{}, fmt.Errorf("create weather request: %w", err)
-	}
-
-	resp, err = s.client.Do(req)
-	if err != nil {
-		return Metrics{}, fmt.Errorf("weather request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return Metrics{}, fmt.Errorf("weather request failed: status %d, url: %s, body read error: %v", resp.StatusCode, weatherURL, readErr)
-		}
-		return Metrics{}, fmt.Errorf("weather request failed: status %d, url: %s, response: %s", resp.StatusCode, weatherURL, string(bodyBytes))
-	}
-
 	var weatherPayload struct {
 		Hourly struct {
 			Temperature []float64 `json:"temperature_2m"`
 			Humidity    []float64 `json:"relative_humidity_2m"`
 		} `json:"hourly"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&weatherPayload); err != nil {
-		return Metrics{}, fmt.Errorf("decode weather response: %w", err)
+	if err := s.getJSON(weatherURL, "weather", &weatherPayload); err != nil {
+		return Metrics{}, err
 	}
 
 	// Combine data into Metrics
@@ -143,23 +107,99 @@ func (s *Service) GetMetrics(latitude, longitude float64) (Metrics, error) {
 		return Metrics{}, errors.New("air quality response missing CO data")
 	}
 	// Open-Meteo API does not provide population density, use fixed default value.
-	metrics.PopulationDensity = 497
+	metrics.PopulationDensity = defaultPopulationDensity
 
 	return metrics, nil
 }
 
-// FeatureVector returns the ordered feature slice expected by the ML model.
+// Feature is one model input that Metrics can supply.
+type Feature struct {
+	Name  string
+	Value func(Metrics) float64
+}
+
+// Features is the declarative list of model inputs, in FeatureVector order.
+// ML feature mappings refer to these names.
+var Features = []Feature{
+	{Name: "temperature", Value: func(m Metrics) float64 { return m.Temperature }},
+	{Name: "humidity", Value: func(m Metrics) float64 { return m.Humidity }},
+	{Name: "pm2_5", Value: func(m Metrics) float64 { return m.PM25 }},
+	{Name: "pm10", Value: func(m Metrics) float64 { return m.PM10 }},
+	{Name: "no2", Value: func(m Metrics) float64 { return m.NO2 }},
+	{Name: "so2", Value: func(m Metrics) float64 { return m.SO2 }},
+	{Name: "co", Value: func(m Metrics) float64 { return m.CO }},
+	{Name: "population_density", Value: func(m Metrics) float64 { return m.PopulationDensity }},
+}
+
+// Pollutant is a pollutant feature as shown to users.
+type Pollutant struct {
+	Feature   string  // name in Features
+	Label     string  // display name
+	Reference float64 // clean background level (µg/m³), used for sensitivity explanations
+}
+
+// Pollutants lists the pollutant features that explanations may blame.
+var Pollutants = []Pollutant{
+	{Feature: "pm2_5", Label: "PM2.5", Reference: 5},
+	{Feature: "pm10", Label: "PM10", Reference: 15},
+	{Feature: "no2", Label: "NO2", Reference: 10},
+	{Feature: "so2", Label: "SO2", Reference: 5},
+	{Feature: "co", Label: "CO", Reference: 150},
+}
+
+// FeatureNames names the entries of FeatureVector, in the same order.
+var FeatureNames = func() []string {
+	names := make([]string, len(Features))
+	for i, f := range Features {
+		names[i] = f.Name
+	}
+	return names
+}()
+
+// FeatureVector returns the feature values in Features order.
 func (m Metrics) FeatureVector() []float64 {
-	return []float64{
-		m.Temperature,
-		m.Humidity,
-		m.PM25,
-		m.PM10,
-		m.NO2,
-		m.SO2,
-		m.CO,
-		m.PopulationDensity,
+	out := make([]float64, len(Features))
+	for i, f := range Features {
+		out[i] = f.Value(m)
+	}
+	return out
+}
+
+// FeatureMap returns the feature values keyed by Features name.
+func (m Metrics) FeatureMap() map[string]float64 {
+	out := make(map[string]float64, len(Features))
+	for _, f := range Features {
+		out[f.Name] = f.Value(m)
+	}
+	return out
+}
+
+// getJSON performs a GET request and decodes the JSON body into out.
+// name is used in error messages ("air quality", "weather").
+func (s *Service) getJSON(url, name string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", name, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return fmt.Errorf("%s request failed: status %d, url: %s, body read error: %v", name, resp.StatusCode, url, readErr)
+		}
+		return fmt.Errorf("%s request failed: status %d, url: %s, response: %s", name, resp.StatusCode, url, string(bodyBytes))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", name, err)
 	}
+	return nil
 }
 
 func latestFloat(values []float64) (float64, bool) {
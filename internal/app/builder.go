@@ -1,14 +1,19 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"nasa-app/internal/admin"
 	"nasa-app/internal/airquality"
 	"nasa-app/internal/auth"
 	database "nasa-app/internal/db"
 	"nasa-app/internal/middleware"
+	"nasa-app/internal/mlaudit"
 	"nasa-app/internal/mlclient"
 	"nasa-app/internal/notification"
 	user2 "nasa-app/internal/user"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,22 +31,45 @@ func New() *fiber.App {
 		log.Fatalf("db connect: %v", err)
 	}
 
+	if err := notification.DropLegacyUserIndex(db); err != nil {
+		log.Fatalf("db migrate: %v", err)
+	}
 	if err := database.Migrate(db,
 		&user2.User{},
 		&notification.Notification{},
+		&notification.AlertState{},
+		&notification.DeferredAlert{},
+		&notification.PushSubscription{},
+		&notification.OutboxMessage{},
+		&notification.TelegramChat{},
+		&mlaudit.Record{},
+		&mlaudit.Feedback{},
 	); err != nil {
 		log.Fatalf("db migrate: %v", err)
 	}
+	if err := notification.TrustOwnEmails(db); err != nil {
+		log.Printf("trust own subscription emails: %v", err)
+	}
 
 	/* ------------ Services ------------ */
 	userSvc := user2.NewService(user2.NewGormRepo(db))
 	notifRepo := notification.NewRepository(db)
 	aqService := airquality.NewService(nil, cfg.AQIBaseURL)
+	auditRepo := mlaudit.NewRepository(db)
+	linkTokens := notification.NewTokenSigner(cfg.JWT, time.Duration(cfg.LinkTokenDays)*24*time.Hour)
 
-	mailSender := func(email, riskLevel string, aqi int) error {
-		log.Printf("SMTP configuration missing; skipping email to %s (risk=%s)", email, riskLevel)
+	mailSender := func(email string, alert notification.Alert) error {
+		log.Printf("SMTP configuration missing; skipping email to %s (risk=%s)", email, alert.RiskLevel)
+		return nil
+	}
+	digestSender := func(email string, digest notification.Digest) error {
+		log.Printf("SMTP configuration missing; skipping digest to %s", email)
 		return nil
 	}
+	verificationSender := func(n notification.Notification) error {
+		// Hata döner ki gönderilmiş sayılmasın; SMTP gelince tekrar denenir
+		return errors.New("SMTP configuration missing")
+	}
 
 	if cfg.SMTPHost != "" && cfg.SMTPPort != "" && cfg.SMTPFrom != "" {
 		smtpCfg, err := notification.ConfigFromEnv(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
@@ -52,64 +80,204 @@ func New() *fiber.App {
 			if err != nil {
 				log.Printf("mailer init failed: %v", err)
 			} else {
+				mailer.UseLinks(linkTokens, cfg.PublicBaseURL)
 				mailSender = mailer.SendAQIAlert
+				digestSender = mailer.SendDigest
+				verificationSender = mailer.SendVerification
+				go notification.SendPendingVerifications(notifRepo, verificationSender)
 			}
 		}
 	} else {
 		log.Println("SMTP configuration incomplete; notification emails disabled")
 	}
 
-	mlPredictor := func(n notification.Notification, metrics airquality.Metrics) (mlclient.PredictionResponse, error) {
-		return mlclient.PredictionResponse{RiskLevel: "unknown"}, nil
+	ml := newMLStack(cfg, auditRepo)
+	mlBatchPredictor := func(locs []notification.Notification, metrics []airquality.Metrics) ([]mlclient.BatchResult, error) {
+		return ml.predictBatch("scheduler", locs, metrics)
 	}
 
-	if cfg.MLServiceURL != "" {
-		log.Printf("Initializing ML client with URL: %s%s", cfg.MLServiceURL, cfg.MLPredictPath)
-		mlc, err := mlclient.New(cfg.MLServiceURL, cfg.MLPredictPath, nil)
+	// Tekrar denemeleri outbox yapar; gönderici kendi içinde beklemesin
+	webhooks := notification.NewWebhookSender(notification.NewPublicClient(time.Duration(cfg.WebhookTimeoutSecond)*time.Second), 0)
+
+	var pushSender *notification.PushSender
+	if cfg.VAPIDPublicKey != "" || cfg.VAPIDPrivateKey != "" {
+		keys, err := notification.ParseVAPIDKeys(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
 		if err != nil {
-			log.Printf("ml client init failed: %v", err)
+			log.Printf("invalid VAPID configuration: %v; web push disabled", err)
 		} else {
-			log.Println("ML client initialized successfully")
-			mlPredictor = func(n notification.Notification, metrics airquality.Metrics) (mlclient.PredictionResponse, error) {
-				log.Printf("Sending prediction request to ML service for user %d", n.UserID)
-				req := mlclient.PredictionRequest{
-					Temperature:       metrics.Temperature,
-					Humidity:          metrics.Humidity,
-					PM25:              metrics.PM25,
-					PM10:              metrics.PM10,
-					NO2:               metrics.NO2,
-					SO2:               metrics.SO2,
-					CO:                metrics.CO,
-					PopulationDensity: metrics.PopulationDensity,
+			pushSender = notification.NewPushSender(keys, notifRepo, nil)
+		}
+	} else {
+		log.Println("VAPID keys missing; web push disabled (generate a pair with: go run ./cmd/vapidkeys)")
+	}
+
+	ownPhone := func(userID uint) (string, error) {
+		u, err := userSvc.GetByID(userID)
+		if err != nil {
+			return "", err
+		}
+		return u.VerifiedPhone(), nil
+	}
+	// SMS yalnızca açıkça seçilirse; yanlış yapılandırma sessizce log'a düşmesin
+	var smsProvider notification.SMSProvider
+	switch cfg.SMSProvider {
+	case "":
+		log.Println("SMS_PROVIDER not set; sms alerts disabled")
+	case "http":
+		provider, err := notification.NewHTTPSMSProvider(nil, cfg.SMSAPIURL, cfg.SMSAccount, cfg.SMSToken, cfg.SMSFrom)
+		if err != nil {
+			log.Fatalf("invalid SMS configuration: %v", err)
+		}
+		smsProvider = provider
+	case "log":
+		log.Println("SMS_PROVIDER=log: sms alerts are written to the log/SMS_LOG_FILE, not sent")
+		smsProvider = notification.NewLogSMSProvider(cfg.SMSLogFile)
+	default:
+		log.Fatalf("invalid SMS_PROVIDER %q: must be http, log or empty", cfg.SMSProvider)
+	}
+	if smsProvider != nil {
+		userSvc.UsePhoneVerification(func(phone, code string) error {
+			return smsProvider.SendSMS(phone, "Clean Breathing doğrulama kodu / verification code: "+code)
+		}, cfg.JWT)
+	}
+
+	chatSender := notification.NewChatSender(nil, cfg.TelegramAPIURL, cfg.TelegramBotToken)
+	telegramEnabled := cfg.TelegramBotToken != "" && cfg.TelegramWebhookSecret != ""
+	switch {
+	case cfg.TelegramBotToken != "" && cfg.TelegramWebhookSecret == "":
+		log.Printf("TELEGRAM_BOT_TOKEN is set without TELEGRAM_WEBHOOK_SECRET; telegram alerts are disabled")
+	case telegramEnabled && !notification.TelegramWebhookSecret.MatchString(cfg.TelegramWebhookSecret):
+		log.Fatalf("invalid TELEGRAM_WEBHOOK_SECRET: use 1-256 letters, digits, _ or -")
+	case telegramEnabled && cfg.PublicBaseURL != "":
+		if err := chatSender.SetTelegramWebhook(strings.TrimRight(cfg.PublicBaseURL, "/")+"/telegram/webhook", cfg.TelegramWebhookSecret); err != nil {
+			log.Printf("telegram setWebhook: %v", err)
+		}
+	case telegramEnabled:
+		log.Printf("PUBLIC_BASE_URL is not set; register the telegram webhook by hand")
+	}
+
+	// Kanal başına gönderici; e-posta yalnızca doğrulanmış adrese gider
+	channelSenders := map[string]func(notification.Alert) error{
+		notification.ChannelEmail: func(alert notification.Alert) error {
+			n := alert.Subscription
+			if !n.CanEmail() {
+				if n.Email != "" {
+					log.Printf("subscription %d email %s not verified; skipping alert", n.ID, n.Email)
 				}
-				return mlc.Predict(req)
+				return nil
+			}
+			return mailSender(n.Email, alert)
+		},
+		notification.ChannelWebhook: webhooks.SendAlert,
+		notification.ChannelSlack:   chatSender.SendSlack,
+		notification.ChannelDiscord: chatSender.SendDiscord,
+	}
+	if telegramEnabled {
+		// Bağlantısı kaldırılmış sohbetlere gönderilmez
+		channelSenders[notification.ChannelTelegram] = func(alert notification.Alert) error {
+			n := alert.Subscription
+			linked, err := notifRepo.TelegramLinked(n.UserID, n.TelegramChatID)
+			if err != nil {
+				return err
+			}
+			if !linked {
+				log.Printf("subscription %d telegram chat %s not linked; skipping alert", n.ID, n.TelegramChatID)
+				return nil
 			}
+			return chatSender.SendTelegram(alert)
 		}
-	} else {
-		log.Println("ML service URL missing; using fallback predictor")
 	}
+	if pushSender != nil {
+		channelSenders[notification.ChannelPush] = pushSender.SendAlert
+	}
+	if smsProvider != nil {
+		channelSenders[notification.ChannelSMS] = notification.NewSMSSender(smsProvider, ownPhone).SendAlert // hazardous alerts only
+	}
+
+	// Uyarılar önce outbox'a yazılır; teslimatı ayrı worker'lar yapar
+	alertNotifier := func(alert notification.Alert) error {
+		n := alert.Subscription
+		var channels []string
+		for _, ch := range notification.Channels {
+			if _, ok := channelSenders[ch]; !ok || !n.HasChannel(ch) {
+				continue
+			}
+			if (ch == notification.ChannelEmail && !n.CanEmail()) || (ch == notification.ChannelSMS && !alert.Hazardous()) {
+				continue
+			}
+			channels = append(channels, ch)
+		}
+		if len(channels) == 0 {
+			return nil
+		}
 
-	alertNotifier := func(n notification.Notification, metrics airquality.Metrics, prediction mlclient.PredictionResponse) error {
-		riskLevel := prediction.RiskLevel
-		if riskLevel == "" {
-			riskLevel = "unknown"
+		// Uyarı başlıca kirleticileri de söylesin
+		if alert.Kind == notification.KindAlert {
+			// Zamanlayıcının tahmini kullanılır; ikinci tahmin ve audit satırı yok
+			if explained, err := ml.explainPrediction(alert.Metrics, alert.Prediction); err != nil {
+				log.Printf("alert explanation failed for user %d: %v", n.UserID, err)
+			} else {
+				for _, d := range ml.drivers(explained) {
+					alert.Drivers = append(alert.Drivers, d.Pollutant)
+				}
+			}
 		}
-		return mailSender(n.Email, riskLevel, 0)
+		return notifRepo.Enqueue(alert, channels)
+	}
+	deliverAlert := func(channel string, alert notification.Alert) error {
+		send, ok := channelSenders[channel]
+		if !ok {
+			return fmt.Errorf("channel %s is not configured", channel)
+		}
+		return send(alert)
 	}
 
 	// ML predictor for air quality endpoint
-	aqMLPredictor := func(latitude, longitude float64, metrics airquality.Metrics) (string, error) {
-		prediction, err := mlPredictor(notification.Notification{Latitude: latitude, Longitude: longitude}, metrics)
+	aqMLPredictor := func(latitude, longitude float64, metrics airquality.Metrics, explain bool) (airquality.Prediction, error) {
+		loc := notification.Notification{Latitude: latitude, Longitude: longitude}
+		if explain {
+			prediction, auditID, err := ml.explain("api", loc, metrics)
+			if err != nil {
+				return airquality.Prediction{RiskLevel: "unknown"}, err
+			}
+			out := toAirQualityPrediction(prediction)
+			out.ID = auditID
+			out.Drivers = ml.drivers(prediction)
+			return out, nil
+		}
+
+		prediction, auditID, err := ml.predict("api", loc, metrics)
 		if err != nil {
-			return "unknown", err
+			return airquality.Prediction{RiskLevel: "unknown"}, err
 		}
-		return prediction.RiskLevel, nil
+		out := toAirQualityPrediction(prediction)
+		out.ID = auditID
+		return out, nil
 	}
 
 	/* ------------ Handlers ------------ */
 	userHdl := user2.NewHandler(userSvc)
-	notifHdl := notification.NewHandler(notifRepo, nil) // session store ileride eklenecek
-	aqHdl := airquality.NewHandler(aqService, aqMLPredictor)
+	notifHdl := notification.NewHandler(notifRepo, nil, linkTokens) // session store ileride eklenecek
+	notifHdl.UseVerification(func(userID uint) (string, error) {
+		u, err := userSvc.GetByID(userID)
+		if err != nil {
+			return "", err
+		}
+		return u.Email, nil
+	}, verificationSender)
+	if pushSender != nil {
+		notifHdl.UsePush(pushSender.PublicKey())
+	}
+	if smsProvider != nil {
+		notifHdl.UsePhone(ownPhone)
+	}
+	if telegramEnabled {
+		notifHdl.UseTelegram(cfg.TelegramBotUsername, cfg.TelegramWebhookSecret, chatSender.SendTelegramText)
+	}
+	forecaster := airquality.NewForecaster(aqService, ml.forecast, time.Duration(cfg.ForecastCacheMinute)*time.Minute)
+	aqHdl := airquality.NewHandler(aqService, aqMLPredictor, forecaster)
+	feedbackHdl := mlaudit.NewHandler(auditRepo, ml.audits, cfg.SchedulerGridDegrees)
+	adminHdl := admin.NewHandler(ml.shadow, ml.drift, auditRepo, notifRepo)
 
 	/* ------------ Fiber ------------ */
 	app := fiber.New(fiber.Config{
@@ -124,6 +292,12 @@ func New() *fiber.App {
 		},
 	})
 
+	// Kapanışta kuyruktaki audit kayıtları yazılsın
+	app.Hooks().OnShutdown(func() error {
+		ml.audits.Close()
+		return nil
+	})
+
 	srv := app.Server()
 	srv.ReadTimeout = 10 * time.Second
 	srv.WriteTimeout = 15 * time.Second
@@ -138,7 +312,16 @@ func New() *fiber.App {
 
 	// ✅ Health check endpoint
 	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{"status": "ok"})
+		if ml.client == nil {
+			return c.JSON(fiber.Map{"status": "ok"})
+		}
+		// ML breaker açıksa servis ayakta ama tahminler fallback'e düşüyor
+		mlHealth := ml.client.Health()
+		status := "ok"
+		if mlHealth.State != mlclient.StateClosed.String() {
+			status = "degraded"
+		}
+		return c.JSON(fiber.Map{"status": status, "ml": mlHealth})
 	})
 
 	/* ------------ Public routes ------------ */
@@ -146,23 +329,87 @@ func New() *fiber.App {
 	app.Get("/auth/google/callback", auth.Callback(userSvc))
 	app.Get("/logout", auth.Logout)
 	app.Get("/air-quality", aqHdl.GetAirQuality)
+	app.Get("/air-quality/forecast", aqHdl.GetForecast)
+	app.Get("/notifications/unsubscribe", notifHdl.Unsubscribe)
+	app.Post("/notifications/unsubscribe", notifHdl.Unsubscribe)
+	app.Get("/notifications/verify", notifHdl.VerifyEmail)
+	app.Post("/notifications/verify", notifHdl.VerifyEmail)
+	app.Get("/notifications/push-key", notifHdl.PushKey)
+	app.Post("/telegram/webhook", notifHdl.TelegramWebhook)
 
 	/* ------------ Protected routes ------------ */
 	api := app.Group("/", middleware.Auth())
 	api.Get("/me", userHdl.Me)
+	api.Put("/me/phone", userHdl.UpdatePhone)
+	api.Post("/me/phone/verify", userHdl.ConfirmPhone)
 	api.Post("/notifications/subscribe", notifHdl.Subscribe)
+	api.Get("/notifications", notifHdl.List)
+	api.Post("/notifications", notifHdl.Create)
+	api.Put("/notifications/:id", notifHdl.Update)
+	api.Delete("/notifications/:id", notifHdl.Delete)
+	api.Post("/notifications/:id/verify/resend", notifHdl.ResendVerification)
+	api.Post("/notifications/push-subscriptions", notifHdl.SubscribePush)
+	api.Delete("/notifications/push-subscriptions", notifHdl.UnsubscribePush)
+	api.Post("/notifications/telegram/link", notifHdl.LinkTelegram)
+	api.Get("/notifications/telegram/chats", notifHdl.TelegramChats)
+	api.Delete("/notifications/telegram/chats/:id", notifHdl.UnlinkTelegram)
+	api.Post("/air-quality/feedback", feedbackHdl.SubmitFeedback)
+
+	/* ------------ Admin routes ------------ */
+	adminAPI := api.Group("/admin", middleware.Admin(cfg.AdminUserIDs))
+	adminAPI.Get("/ml/shadow", adminHdl.ShadowReport)
+	adminAPI.Get("/ml/drift", adminHdl.DriftReport)
+	adminAPI.Get("/ml/training-set", adminHdl.TrainingSet)
+	adminAPI.Get("/notifications/outbox", adminHdl.Outbox)
+	adminAPI.Post("/notifications/outbox/requeue", adminHdl.RequeueOutbox)
+	adminAPI.Post("/notifications/outbox/:id/requeue", adminHdl.RequeueOutbox)
 
 	// Bildirim scheduler'ı başlat
 	interval := time.Duration(cfg.NotificationIntervalMinute) * time.Minute
 	notification.StartScheduler(
 		notifRepo,
 		interval,
+		notification.AlertPolicy{
+			Cooldown:      time.Duration(cfg.AlertCooldownMinute) * time.Minute,
+			Hysteresis:    float64(cfg.AlertHysteresisPercent) / 100,
+			AllClearAfter: time.Duration(cfg.AlertAllClearMinute) * time.Minute,
+		},
+		notification.ScanOptions{
+			CellDegrees: cfg.SchedulerGridDegrees,
+			Workers:     cfg.SchedulerWorkers,
+		},
 		func(n notification.Notification) (airquality.Metrics, error) {
 			return aqService.GetMetrics(n.Latitude, n.Longitude)
 		},
-		mlPredictor,
+		mlBatchPredictor,
 		alertNotifier,
 	)
 
+	notification.StartOutboxWorkers(
+		notifRepo,
+		cfg.OutboxWorkers,
+		time.Duration(cfg.OutboxPollSecond)*time.Second,
+		notification.OutboxPolicy{
+			MaxAttempts: cfg.OutboxMaxAttempts,
+			Backoff:     time.Duration(cfg.OutboxBackoffSecond) * time.Second,
+			MaxBackoff:  time.Hour,
+			Retention:   time.Duration(cfg.OutboxRetentionDays) * 24 * time.Hour,
+		},
+		deliverAlert,
+	)
+
+	notification.StartDigestScheduler(
+		notifRepo,
+		time.Duration(cfg.DigestCheckMinute)*time.Minute,
+		aqService.GetPollutantStats,
+		forecaster.Timeline,
+		func(d notification.Digest) error {
+			if !d.Subscription.CanEmail() {
+				return nil
+			}
+			return digestSender(d.Subscription.Email, d)
+		},
+	)
+
 	return app
 }
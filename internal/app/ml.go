@@ -0,0 +1,353 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"nasa-app/internal/airquality"
+	"nasa-app/internal/config"
+	"nasa-app/internal/mlaudit"
+	"nasa-app/internal/mlclient"
+	"nasa-app/internal/notification"
+)
+
+// maxDrivers is how many pollutants an explanation names.
+const maxDrivers = 3
+
+// mlStack holds the configured prediction backend (in-process tree model or
+// HTTP ML service) and the pieces around it (shadow evaluation, audit log,
+// drift monitor). Without a backend every prediction falls back to "unknown".
+type mlStack struct {
+	client    *mlclient.Client // nil unless the HTTP service is used
+	predictor mlclient.Predictor
+	shadow    *mlclient.Shadow
+	drift     *mlaudit.DriftMonitor
+	audits    *mlaudit.Writer // async, batched audit log inserts
+	mapping   mlclient.FeatureMapping
+	// remote explainer (SHAP from the ML service) is tried first, local
+	// sensitivity deltas are the fallback and the only option for the tree model
+	remoteExplainer mlclient.Explainer
+	localExplainer  *mlclient.SensitivityExplainer
+}
+
+// schemaModes are the accepted ML_SCHEMA_MODE values.
+var schemaModes = []string{"strict", "fallback", "off"}
+
+// schemaFetchAttempts is how often strict mode tries to reach the schema
+// endpoint (waiting 1s, 2s, 4s, ... in between) before giving up.
+const schemaFetchAttempts = 5
+
+func newMLStack(cfg config.Config, auditRepo *mlaudit.Repository) *mlStack {
+	if !slices.Contains(schemaModes, cfg.MLSchemaMode) {
+		log.Fatalf("invalid ML_SCHEMA_MODE %q: must be one of %s", cfg.MLSchemaMode, strings.Join(schemaModes, ", "))
+	}
+	ml := &mlStack{audits: mlaudit.NewWriter(auditRepo), mapping: mlclient.DefaultFeatureMapping}
+	if cfg.MLFeatureMap != "" {
+		mapping, err := mlclient.ParseFeatureMapping(cfg.MLFeatureMap)
+		if err != nil {
+			log.Fatalf("invalid ML_FEATURE_MAP: %v", err)
+		}
+		ml.mapping = mapping
+	}
+
+	var schema func() (mlclient.Schema, error)
+	switch {
+	case cfg.MLModelPath != "":
+		// Yerel model varsa harici ML servisine gerek yok
+		model, err := mlclient.LoadTreeModel(cfg.MLModelPath)
+		if err != nil {
+			// Model açıkça istendi; sessizce ML'siz çalışmak yerine başlamayı reddet
+			log.Fatalf("invalid ML_MODEL_PATH: tree model load failed: %v", err)
+		}
+		log.Printf("Using in-process tree model from %s", cfg.MLModelPath)
+		ml.predictor = model
+		schema = func() (mlclient.Schema, error) { return model.Schema(), nil }
+
+	case cfg.MLServiceURL != "":
+		log.Printf("Initializing ML client with URL: %s%s", cfg.MLServiceURL, cfg.MLPredictPath)
+		mlc, err := mlclient.New(cfg.MLServiceURL, cfg.MLPredictPath, nil)
+		if err != nil {
+			log.Printf("ml client init failed: %v", err)
+			return ml
+		}
+		mlc.UseBreaker(mlclient.NewBreaker(cfg.MLBreakerFailures, time.Duration(cfg.MLBreakerCooldownSecond)*time.Second))
+		mlc.UseBatchConcurrency(cfg.MLBatchConcurrency)
+		mlc.UseBatchSize(cfg.MLBatchSize)
+		log.Println("ML client initialized successfully")
+		ml.client = mlc
+		ml.predictor = mlc
+		schema = mlc.FetchSchema
+
+	default:
+		log.Println("ML service URL missing; using fallback predictor")
+		return ml
+	}
+
+	if !ml.schemaMatches(cfg.MLSchemaMode, schema) {
+		ml.predictor = nil
+		return ml
+	}
+
+	ml.remoteExplainer, _ = ml.predictor.(mlclient.Explainer)
+	ml.localExplainer = mlclient.NewSensitivityExplainer(ml.predictor, ml.pollutantReference())
+
+	if cfg.MLShadowURL != "" {
+		candidate, err := mlclient.New(cfg.MLShadowURL, cfg.MLShadowPredictPath, nil)
+		if err != nil {
+			log.Printf("ml shadow client init failed: %v", err)
+		} else {
+			ml.shadow = mlclient.NewShadow(ml.predictor, candidate, 0)
+			ml.predictor = ml.shadow
+			log.Printf("ML shadow evaluation enabled against %s", cfg.MLShadowURL)
+		}
+	}
+
+	ml.drift = mlaudit.NewDriftMonitor(auditRepo, airquality.FeatureNames,
+		time.Duration(cfg.MLDriftBaselineDays)*24*time.Hour,
+		time.Duration(cfg.MLDriftWindowHours)*time.Hour)
+	ml.drift.Start(time.Duration(cfg.MLDriftIntervalMinute) * time.Minute)
+
+	return ml
+}
+
+// schemaMatches checks the feature mapping against the model's schema. In
+// strict mode a mismatch, or a schema that stays unreachable, stops the
+// process; otherwise the caller falls back. A backend without a schema is
+// used unchecked.
+func (ml *mlStack) schemaMatches(mode string, fetch func() (mlclient.Schema, error)) bool {
+	if mode == "off" {
+		return true
+	}
+
+	schema, err := fetch()
+	if mode == "strict" {
+		wait := time.Second
+		for attempt := 1; err != nil && !errors.Is(err, mlclient.ErrSchemaUnavailable) && attempt < schemaFetchAttempts; attempt++ {
+			log.Printf("ML schema fetch failed (attempt %d/%d): %v; retrying in %s", attempt, schemaFetchAttempts, err, wait)
+			time.Sleep(wait)
+			wait *= 2
+			schema, err = fetch()
+		}
+	}
+	if errors.Is(err, mlclient.ErrSchemaUnavailable) {
+		log.Println("ML backend publishes no feature schema; using feature mapping unchecked")
+		return true
+	}
+	if err != nil {
+		if mode == "strict" {
+			log.Fatalf("ml schema fetch: %v", err)
+		}
+		log.Printf("ML schema fetch failed: %v; using feature mapping unchecked", err)
+		return true
+	}
+
+	unused, err := schema.Check(ml.mapping, airquality.FeatureNames)
+	if len(unused) > 0 {
+		log.Printf("ML schema %q does not use mapped features: %v", schema.Version, unused)
+	}
+	if err != nil {
+		if mode == "strict" {
+			log.Fatalf("ml schema check: %v", err)
+		}
+		log.Printf("ml schema check: %v; falling back to default predictor", err)
+		return false
+	}
+
+	log.Printf("ML feature schema %q validated (%d features)", schema.Version, len(schema.Features))
+	return true
+}
+
+// predict runs a single prediction and writes it to the audit log. It also
+// returns the audit record ID (0 when nothing was recorded).
+func (ml *mlStack) predict(source string, n notification.Notification, metrics airquality.Metrics) (mlclient.PredictionResponse, uint, error) {
+	if ml.predictor == nil {
+		return mlclient.PredictionResponse{RiskLevel: "unknown"}, 0, nil
+	}
+
+	log.Printf("Sending prediction request to ML service for user %d", n.UserID)
+	req, err := ml.mapping.Build(metrics.FeatureMap())
+	if err != nil {
+		return mlclient.PredictionResponse{}, 0, err
+	}
+	start := time.Now()
+	prediction, err := ml.predictor.Predict(req)
+	return prediction, ml.audit(source, n, metrics, prediction, err, time.Since(start)), err
+}
+
+// audit queues a single ML call for the audit log and returns its record ID.
+func (ml *mlStack) audit(source string, n notification.Notification, metrics airquality.Metrics, prediction mlclient.PredictionResponse, err error, latency time.Duration) uint {
+	return ml.audits.Add(auditRecord(source, n, metrics, prediction, err, latency))
+}
+
+// predictBatch runs one batched prediction and writes every item to the audit
+// log at its location (for the scheduler, the grid cell centre).
+func (ml *mlStack) predictBatch(source string, locs []notification.Notification, metrics []airquality.Metrics) ([]mlclient.BatchResult, error) {
+	if ml.predictor == nil {
+		results := make([]mlclient.BatchResult, len(metrics))
+		for i := range results {
+			results[i].Prediction = mlclient.PredictionResponse{RiskLevel: "unknown"}
+		}
+		return results, nil
+	}
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("Sending batch prediction request to ML service for %d items (%s)", len(metrics), source)
+	reqs := make([]mlclient.PredictionRequest, len(metrics))
+	for i, m := range metrics {
+		req, err := ml.mapping.Build(m.FeatureMap())
+		if err != nil {
+			return nil, err
+		}
+		reqs[i] = req
+	}
+	start := time.Now()
+	results, err := ml.predictor.PredictBatch(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Batch gecikmesi istek başına eşit paylaştırılır
+	latency := time.Since(start) / time.Duration(len(reqs))
+	for i, r := range results {
+		ml.audits.Add(auditRecord(source, locs[i], metrics[i], r.Prediction, r.Err, latency))
+	}
+	return results, nil
+}
+
+// explain predicts with per-feature contributions and writes the call to the audit log.
+func (ml *mlStack) explain(source string, n notification.Notification, metrics airquality.Metrics) (mlclient.PredictionResponse, uint, error) {
+	if ml.predictor == nil {
+		return mlclient.PredictionResponse{RiskLevel: "unknown"}, 0, nil
+	}
+
+	req, err := ml.mapping.Build(metrics.FeatureMap())
+	if err != nil {
+		return mlclient.PredictionResponse{}, 0, err
+	}
+
+	start := time.Now()
+	err = mlclient.ErrExplainUnsupported
+	var prediction mlclient.PredictionResponse
+	if ml.remoteExplainer != nil {
+		prediction, err = ml.remoteExplainer.Explain(req)
+	}
+	if errors.Is(err, mlclient.ErrExplainUnsupported) {
+		prediction, err = ml.localExplainer.Explain(req)
+	}
+
+	return prediction, ml.audit(source, n, metrics, prediction, err, time.Since(start)), err
+}
+
+// explainPrediction adds contributions to a prediction that was already made
+// and audited (e.g. by the scheduler). The prediction itself is neither
+// repeated nor audited again; only the sensitivity deltas are computed.
+func (ml *mlStack) explainPrediction(metrics airquality.Metrics, prediction mlclient.PredictionResponse) (mlclient.PredictionResponse, error) {
+	if len(prediction.Meta.Contributions) > 0 || ml.localExplainer == nil {
+		return prediction, nil
+	}
+	if prediction.RiskLevel == "" || prediction.RiskLevel == "unknown" {
+		return prediction, nil
+	}
+	req, err := ml.mapping.Build(metrics.FeatureMap())
+	if err != nil {
+		return mlclient.PredictionResponse{}, err
+	}
+	return ml.localExplainer.ExplainPrediction(req, prediction)
+}
+
+// drivers translates model feature contributions into the top pollutants.
+func (ml *mlStack) drivers(prediction mlclient.PredictionResponse) []airquality.Driver {
+	labels := map[string]string{}
+	for _, b := range ml.mapping {
+		for _, p := range airquality.Pollutants {
+			if b.Source == p.Feature {
+				labels[b.Feature] = p.Label
+			}
+		}
+	}
+
+	var out []airquality.Driver
+	for _, feature := range prediction.TopContributions(len(prediction.Meta.Contributions)) {
+		label, ok := labels[feature]
+		if !ok {
+			continue
+		}
+		out = append(out, airquality.Driver{Pollutant: label, Contribution: prediction.Meta.Contributions[feature]})
+		if len(out) == maxDrivers {
+			break
+		}
+	}
+	return out
+}
+
+// pollutantReference maps the clean-air reference of every pollutant to its model feature name.
+func (ml *mlStack) pollutantReference() mlclient.PredictionRequest {
+	ref := mlclient.PredictionRequest{}
+	for _, b := range ml.mapping {
+		for _, p := range airquality.Pollutants {
+			if b.Source == p.Feature {
+				ref[b.Feature] = p.Reference
+			}
+		}
+	}
+	return ref
+}
+
+// forecast predicts every forecast hour of one location in a single batch.
+func (ml *mlStack) forecast(latitude, longitude float64, metrics []airquality.Metrics) ([]airquality.Prediction, error) {
+	locs := make([]notification.Notification, len(metrics))
+	for i := range locs {
+		locs[i] = notification.Notification{Latitude: latitude, Longitude: longitude}
+	}
+
+	results, err := ml.predictBatch("forecast", locs, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]airquality.Prediction, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			out[i] = airquality.Prediction{RiskLevel: "unknown"}
+			continue
+		}
+		out[i] = toAirQualityPrediction(r.Prediction)
+	}
+	return out, nil
+}
+
+// toAirQualityPrediction exposes the ML response and its metadata to the air quality API.
+func toAirQualityPrediction(p mlclient.PredictionResponse) airquality.Prediction {
+	out := airquality.Prediction{
+		RiskLevel:     p.RiskLevel,
+		Probabilities: p.Meta.Probabilities,
+		ModelVersion:  p.Meta.ModelVersion,
+		SchemaVersion: p.Meta.SchemaVersion,
+	}
+	if confidence, ok := p.Confidence(); ok {
+		out.Confidence = &confidence
+	}
+	return out
+}
+
+// auditRecord builds the audit log entry for one ML call.
+func auditRecord(source string, n notification.Notification, metrics airquality.Metrics, prediction mlclient.PredictionResponse, err error, latency time.Duration) mlaudit.Record {
+	rec := mlaudit.Record{
+		Source:       source,
+		Latitude:     n.Latitude,
+		Longitude:    n.Longitude,
+		Features:     metrics.FeatureVector(),
+		RiskLevel:    prediction.RiskLevel,
+		ModelVersion: prediction.Meta.ModelVersion,
+		LatencyMS:    float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
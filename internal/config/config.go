@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -18,8 +19,49 @@ type Config struct {
 	SMTPFrom                   string
 	AQIBaseURL                 string
 	NotificationIntervalMinute int
+	SchedulerWorkers           int
+	SchedulerGridDegrees       float64
+	ForecastCacheMinute        int
+	AlertCooldownMinute        int
+	AlertHysteresisPercent     int
+	AlertAllClearMinute        int
+	DigestCheckMinute          int
+	OutboxWorkers              int
+	OutboxPollSecond           int
+	OutboxMaxAttempts          int
+	OutboxBackoffSecond        int
+	OutboxRetentionDays        int
+	WebhookTimeoutSecond       int
+	VAPIDPublicKey             string
+	VAPIDPrivateKey            string
+	VAPIDSubject               string
+	SMSProvider                string
+	SMSAPIURL                  string
+	SMSAccount                 string
+	SMSToken                   string
+	SMSFrom                    string
+	SMSLogFile                 string
+	TelegramBotToken           string
+	TelegramAPIURL             string
+	TelegramWebhookSecret      string
+	TelegramBotUsername        string
 	MLServiceURL               string
+	MLModelPath                string
+	MLFeatureMap               string
+	MLSchemaMode               string
 	MLPredictPath              string
+	MLBreakerFailures          int
+	MLBreakerCooldownSecond    int
+	MLBatchConcurrency         int
+	MLBatchSize                int
+	MLShadowURL                string
+	MLShadowPredictPath        string
+	MLDriftBaselineDays        int
+	MLDriftWindowHours         int
+	MLDriftIntervalMinute      int
+	AdminUserIDs               []uint
+	PublicBaseURL              string
+	LinkTokenDays              int
 }
 
 func Load() Config {
@@ -36,8 +78,49 @@ func Load() Config {
 		SMTPFrom:                   env("SMTP_FROM", ""),
 		AQIBaseURL:                 env("AQI_BASE_URL", ""),
 		NotificationIntervalMinute: envInt("NOTIFICATION_INTERVAL_MIN", 30),
+		SchedulerWorkers:           envInt("SCHEDULER_WORKERS", 8),
+		SchedulerGridDegrees:       envFloat("SCHEDULER_GRID_DEG", 0.05),
+		ForecastCacheMinute:        envInt("FORECAST_CACHE_MIN", 60),
+		AlertCooldownMinute:        envInt("ALERT_COOLDOWN_MIN", 180),
+		AlertHysteresisPercent:     envInt("ALERT_HYSTERESIS_PCT", 10),
+		AlertAllClearMinute:        envInt("ALERT_ALL_CLEAR_MIN", 60),
+		DigestCheckMinute:          envInt("DIGEST_CHECK_MIN", 5),
+		OutboxWorkers:              envInt("OUTBOX_WORKERS", 2),
+		OutboxPollSecond:           envInt("OUTBOX_POLL_SEC", 10),
+		OutboxMaxAttempts:          envInt("OUTBOX_MAX_ATTEMPTS", 8),
+		OutboxBackoffSecond:        envInt("OUTBOX_BACKOFF_SEC", 30),
+		OutboxRetentionDays:        envInt("OUTBOX_RETENTION_DAYS", 7),
+		WebhookTimeoutSecond:       envInt("WEBHOOK_TIMEOUT_SEC", 10),
+		VAPIDPublicKey:             env("VAPID_PUBLIC_KEY", ""),
+		VAPIDPrivateKey:            env("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:               env("VAPID_SUBJECT", ""),
+		SMSProvider:                env("SMS_PROVIDER", ""),
+		SMSAPIURL:                  env("SMS_API_URL", ""),
+		SMSAccount:                 env("SMS_ACCOUNT_SID", ""),
+		SMSToken:                   env("SMS_AUTH_TOKEN", ""),
+		SMSFrom:                    env("SMS_FROM", ""),
+		SMSLogFile:                 env("SMS_LOG_FILE", ""),
+		TelegramBotToken:           env("TELEGRAM_BOT_TOKEN", ""),
+		TelegramAPIURL:             env("TELEGRAM_API_URL", ""),
+		TelegramWebhookSecret:      env("TELEGRAM_WEBHOOK_SECRET", ""),
+		TelegramBotUsername:        env("TELEGRAM_BOT_USERNAME", ""),
 		MLServiceURL:               env("ML_SERVICE_URL", ""),
+		MLModelPath:                env("ML_MODEL_PATH", ""),
+		MLFeatureMap:               env("ML_FEATURE_MAP", ""),
+		MLSchemaMode:               env("ML_SCHEMA_MODE", "fallback"),
 		MLPredictPath:              env("ML_PREDICT_PATH", ""),
+		MLBreakerFailures:          envInt("ML_BREAKER_FAILURES", 5),
+		MLBreakerCooldownSecond:    envInt("ML_BREAKER_COOLDOWN_SEC", 30),
+		MLBatchConcurrency:         envInt("ML_BATCH_CONCURRENCY", 8),
+		MLBatchSize:                envInt("ML_BATCH_SIZE", 256),
+		MLShadowURL:                env("ML_SHADOW_URL", ""),
+		MLShadowPredictPath:        env("ML_SHADOW_PREDICT_PATH", ""),
+		MLDriftBaselineDays:        envInt("ML_DRIFT_BASELINE_DAYS", 28),
+		MLDriftWindowHours:         envInt("ML_DRIFT_WINDOW_HOURS", 24),
+		MLDriftIntervalMinute:      envInt("ML_DRIFT_INTERVAL_MIN", 60),
+		AdminUserIDs:               envUintList("ADMIN_USER_IDS"),
+		PublicBaseURL:              env("PUBLIC_BASE_URL", ""),
+		LinkTokenDays:              envInt("LINK_TOKEN_DAYS", 60),
 	}
 }
 
@@ -57,6 +140,26 @@ func envInt(k string, def int) int {
 	return def
 }
 
+func envFloat(k string, def float64) float64 {
+	if v := os.Getenv(k); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return def
+}
+
+// envUintList parses a comma-separated list of IDs, skipping invalid entries.
+func envUintList(k string) []uint {
+	var out []uint
+	for _, part := range strings.Split(os.Getenv(k), ",") {
+		if n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil {
+			out = append(out, uint(n))
+		}
+	}
+	return out
+}
+
 func buildDSN() string {
 	if url := os.Getenv("DATABASE_URL"); url != "" {
 		return url
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Admin lets through only the given user IDs. It must run after Auth.
+func Admin(adminIDs []uint) fiber.Handler {
+	allowed := make(map[uint]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		allowed[id] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		uid, ok := c.Locals("user_id").(uint)
+		if !ok || !allowed[uid] {
+			log.Printf("ADMIN: access denied for user_id=%v", c.Locals("user_id"))
+			return fiber.ErrForbidden
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,279 @@
+package mlaudit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	psiBins        = 10
+	psiEpsilon     = 1e-4
+	maxDriftRows   = 20000
+	minDriftRows   = 30
+	psiModerate    = 0.1
+	psiSignificant = 0.2
+	ksAlpha        = 0.01
+	// ksMinEffect is the smallest KS statistic (largest gap between the two
+	// CDFs) worth reporting. With thousands of rows the p-value alone falls
+	// below ksAlpha for shifts far too small to matter to the model.
+	ksMinEffect = 0.1
+)
+
+// ErrNotEnoughData is returned when either window has too few audited calls to compare.
+var ErrNotEnoughData = errors.New("not enough audited predictions for drift analysis")
+
+// FeatureDrift compares one feature between the baseline and the current window.
+type FeatureDrift struct {
+	Name         string  `json:"name"`
+	BaselineMean float64 `json:"baseline_mean"`
+	BaselineStd  float64 `json:"baseline_std"`
+	WindowMean   float64 `json:"window_mean"`
+	WindowStd    float64 `json:"window_std"`
+	PSI          float64 `json:"psi"`
+	KS           float64 `json:"ks"`
+	KSPValue     float64 `json:"ks_p_value"`
+	// Status is drift when PSI is significant or the KS test is both
+	// significant and at least ksMinEffect; moderate for a moderate PSI.
+	Status string `json:"status"` // stable, moderate, drift
+}
+
+// DriftReport is the result of one drift run.
+type DriftReport struct {
+	GeneratedAt     time.Time      `json:"generated_at"`
+	BaselineFrom    time.Time      `json:"baseline_from"`
+	BaselineTo      time.Time      `json:"baseline_to"`
+	WindowFrom      time.Time      `json:"window_from"`
+	WindowTo        time.Time      `json:"window_to"`
+	BaselineSamples int            `json:"baseline_samples"`
+	WindowSamples   int            `json:"window_samples"`
+	Drifted         []string       `json:"drifted"`
+	Features        []FeatureDrift `json:"features"`
+}
+
+// DriftMonitor periodically compares the recent feature distribution with a baseline window
+// that ends where the recent window starts.
+type DriftMonitor struct {
+	repo         *Repository
+	featureNames []string
+	baseline     time.Duration
+	window       time.Duration
+
+	mu   sync.Mutex
+	last *DriftReport
+}
+
+// NewDriftMonitor creates a monitor. featureNames must follow the order of the audited feature vectors.
+func NewDriftMonitor(repo *Repository, featureNames []string, baseline, window time.Duration) *DriftMonitor {
+	if baseline <= 0 {
+		baseline = 28 * 24 * time.Hour
+	}
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	return &DriftMonitor{
+		repo:         repo,
+		featureNames: featureNames,
+		baseline:     baseline,
+		window:       window,
+	}
+}
+
+// Start runs Compute every interval in the background.
+func (m *DriftMonitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		for {
+			report, err := m.Compute()
+			if err != nil {
+				log.Println("Drift monitor:", err)
+			} else if len(report.Drifted) > 0 {
+				log.Printf("Drift monitor: feature drift detected in %v", report.Drifted)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Latest returns the last computed report.
+func (m *DriftMonitor) Latest() (DriftReport, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return DriftReport{}, false
+	}
+	return *m.last, true
+}
+
+// Compute loads both windows from the audit log and stores a fresh report.
+func (m *DriftMonitor) Compute() (DriftReport, error) {
+	now := time.Now().UTC()
+	report := DriftReport{
+		GeneratedAt:  now,
+		WindowTo:     now,
+		WindowFrom:   now.Add(-m.window),
+		BaselineTo:   now.Add(-m.window),
+		BaselineFrom: now.Add(-m.window - m.baseline),
+	}
+
+	baseline, err := m.repo.FeaturesBetween(report.BaselineFrom, report.BaselineTo, maxDriftRows)
+	if err != nil {
+		return DriftReport{}, fmt.Errorf("load baseline features: %w", err)
+	}
+	current, err := m.repo.FeaturesBetween(report.WindowFrom, report.WindowTo, maxDriftRows)
+	if err != nil {
+		return DriftReport{}, fmt.Errorf("load current features: %w", err)
+	}
+	report.BaselineSamples = len(baseline)
+	report.WindowSamples = len(current)
+	if len(baseline) < minDriftRows || len(current) < minDriftRows {
+		return report, ErrNotEnoughData
+	}
+
+	for i, name := range m.featureNames {
+		b := column(baseline, i)
+		c := column(current, i)
+		if len(b) == 0 || len(c) == 0 {
+			continue
+		}
+
+		fd := compareFeature(name, b, c)
+		if fd.Status == "drift" {
+			report.Drifted = append(report.Drifted, name)
+		}
+		report.Features = append(report.Features, fd)
+	}
+
+	m.mu.Lock()
+	m.last = &report
+	m.mu.Unlock()
+	return report, nil
+}
+
+// compareFeature computes the drift statistics of one feature.
+func compareFeature(name string, baseline, current []float64) FeatureDrift {
+	fd := FeatureDrift{Name: name, PSI: psi(baseline, current)}
+	fd.BaselineMean, fd.BaselineStd = meanStd(baseline)
+	fd.WindowMean, fd.WindowStd = meanStd(current)
+	fd.KS, fd.KSPValue = ks(baseline, current)
+
+	switch {
+	case fd.PSI >= psiSignificant || (fd.KSPValue < ksAlpha && fd.KS >= ksMinEffect):
+		fd.Status = "drift"
+	case fd.PSI >= psiModerate:
+		fd.Status = "moderate"
+	default:
+		fd.Status = "stable"
+	}
+	return fd
+}
+
+// column extracts feature i, skipping vectors that are too short (older schema).
+func column(rows [][]float64, i int) []float64 {
+	out := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		if i < len(row) {
+			out = append(out, row[i])
+		}
+	}
+	return out
+}
+
+func meanStd(xs []float64) (float64, float64) {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+
+	var sq float64
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)))
+}
+
+// psi computes the Population Stability Index using baseline quantile bins.
+func psi(baseline, current []float64) float64 {
+	sorted := append([]float64(nil), baseline...)
+	sort.Float64s(sorted)
+
+	edges := make([]float64, 0, psiBins-1)
+	for i := 1; i < psiBins; i++ {
+		edge := sorted[i*len(sorted)/psiBins]
+		if len(edges) == 0 || edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+
+	b := binShares(baseline, edges)
+	c := binShares(current, edges)
+
+	var total float64
+	for i := range b {
+		total += (c[i] - b[i]) * math.Log(c[i]/b[i])
+	}
+	return total
+}
+
+func binShares(xs, edges []float64) []float64 {
+	counts := make([]float64, len(edges)+1)
+	for _, x := range xs {
+		counts[sort.SearchFloat64s(edges, x)]++
+	}
+	for i := range counts {
+		counts[i] = math.Max(counts[i]/float64(len(xs)), psiEpsilon)
+	}
+	return counts
+}
+
+// ks returns the two-sample Kolmogorov-Smirnov statistic and its asymptotic p-value.
+func ks(a, b []float64) (float64, float64) {
+	a = append([]float64(nil), a...)
+	b = append([]float64(nil), b...)
+	sort.Float64s(a)
+	sort.Float64s(b)
+
+	var d float64
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		x := math.Min(a[i], b[j])
+		for i < len(a) && a[i] <= x {
+			i++
+		}
+		for j < len(b) && b[j] <= x {
+			j++
+		}
+		diff := math.Abs(float64(i)/float64(len(a)) - float64(j)/float64(len(b)))
+		d = math.Max(d, diff)
+	}
+
+	n := float64(len(a)) * float64(len(b)) / float64(len(a)+len(b))
+	lambda := (math.Sqrt(n) + 0.12 + 0.11/math.Sqrt(n)) * d
+	return d, kolmogorovQ(lambda)
+}
+
+// kolmogorovQ is the complementary CDF of the Kolmogorov distribution.
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 1e-3 {
+		return 1
+	}
+	var sum float64
+	sign := 1.0
+	for k := 1; k <= 100; k++ {
+		term := sign * math.Exp(-2*float64(k*k)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-10 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Min(math.Max(2*sum, 0), 1)
+}
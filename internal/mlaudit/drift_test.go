@@ -0,0 +1,37 @@
+package mlaudit
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func normalSample(r *rand.Rand, n int, mean, std float64) []float64 {
+	xs := make([]float64, n)
+	for i := range xs {
+		xs[i] = mean + std*r.NormFloat64()
+	}
+	return xs
+}
+
+func TestCompareFeature(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	baseline := normalSample(r, maxDriftRows, 30, 10)
+
+	tests := []struct {
+		name    string
+		current []float64
+		want    string
+	}{
+		{"same distribution", normalSample(r, maxDriftRows, 30, 10), "stable"},
+		// KS p-value is far below ksAlpha at this size, but the shift is negligible
+		{"negligible shift", normalSample(r, maxDriftRows, 30.5, 10), "stable"},
+		{"shifted mean", normalSample(r, maxDriftRows, 40, 10), "drift"},
+		{"wider spread", normalSample(r, maxDriftRows, 30, 20), "drift"},
+	}
+	for _, tt := range tests {
+		fd := compareFeature("PM2_5", baseline, tt.current)
+		if fd.Status != tt.want {
+			t.Errorf("%s: status %s (psi %.3f, ks %.3f, p %.2g), want %s", tt.name, fd.Status, fd.PSI, fd.KS, fd.KSPValue, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package mlaudit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes the training set with one column per feature.
+func WriteCSV(w io.Writer, rows []TrainingRow, featureNames []string) error {
+	cw := csv.NewWriter(w)
+	header := []string{"feedback_id", "prediction_id", "user_id", "reading_at", "predicted_at", "source", "latitude", "longitude", "model_version", "predicted_risk", "feeling", "corrected_risk"}
+	header = append(header, featureNames...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		record := []string{
+			strconv.FormatUint(uint64(r.ID), 10),
+			strconv.FormatUint(uint64(r.PredictionID), 10),
+			strconv.FormatUint(uint64(r.UserID), 10),
+			r.ReadingAt.UTC().Format(time.RFC3339),
+			formatTime(r.PredictedAt),
+			r.Source,
+			strconv.FormatFloat(r.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(r.Longitude, 'f', -1, 64),
+			r.ModelVersion,
+			r.PredictedRisk,
+			r.Feeling,
+			r.CorrectedRisk,
+		}
+		for i := range featureNames {
+			v := ""
+			if i < len(r.Features) {
+				v = strconv.FormatFloat(r.Features[i], 'f', -1, 64)
+			}
+			record = append(record, v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSONLines writes one JSON object per row, with features keyed by name.
+func WriteJSONLines(w io.Writer, rows []TrainingRow, featureNames []string) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		features := make(map[string]float64, len(featureNames))
+		for i, name := range featureNames {
+			if i < len(r.Features) {
+				features[name] = r.Features[i]
+			}
+		}
+
+		line := struct {
+			FeedbackID    uint               `json:"feedback_id"`
+			PredictionID  uint               `json:"prediction_id"`
+			UserID        uint               `json:"user_id"`
+			ReadingAt     time.Time          `json:"reading_at"`
+			PredictedAt   string             `json:"predicted_at,omitempty"`
+			Source        string             `json:"source,omitempty"`
+			Latitude      float64            `json:"latitude"`
+			Longitude     float64            `json:"longitude"`
+			ModelVersion  string             `json:"model_version"`
+			PredictedRisk string             `json:"predicted_risk"`
+			Feeling       string             `json:"feeling,omitempty"`
+			CorrectedRisk string             `json:"corrected_risk,omitempty"`
+			Features      map[string]float64 `json:"features"`
+		}{
+			FeedbackID:    r.ID,
+			PredictionID:  r.PredictionID,
+			UserID:        r.UserID,
+			ReadingAt:     r.ReadingAt.UTC(),
+			PredictedAt:   formatTime(r.PredictedAt),
+			Source:        r.Source,
+			Latitude:      r.Latitude,
+			Longitude:     r.Longitude,
+			ModelVersion:  r.ModelVersion,
+			PredictedRisk: r.PredictedRisk,
+			Feeling:       r.Feeling,
+			CorrectedRisk: r.CorrectedRisk,
+			Features:      features,
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,30 @@
+package mlaudit
+
+import "time"
+
+// Feedback is a user's report about a reading, stored with the exact features that were predicted.
+type Feedback struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UserID        uint      `gorm:"index" json:"user_id"`
+	PredictionID  uint      `gorm:"index" json:"prediction_id"`
+	ReadingAt     time.Time `json:"reading_at"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Features      []float64 `gorm:"type:jsonb;serializer:json" json:"features"`
+	PredictedRisk string    `gorm:"size:32" json:"predicted_risk"`
+	ModelVersion  string    `gorm:"size:64" json:"model_version"`
+	Feeling       string    `gorm:"size:16" json:"feeling,omitempty"`        // fine, symptoms
+	CorrectedRisk string    `gorm:"size:32" json:"corrected_risk,omitempty"` // user supplied label
+}
+
+func (Feedback) TableName() string { return "prediction_feedback" }
+
+// Feelings accepted in feedback.
+const (
+	FeelingFine     = "fine"
+	FeelingSymptoms = "symptoms"
+)
+
+// RiskLevels are the labels a user may assign.
+var RiskLevels = []string{"good", "moderate", "poor", "hazardous"}
@@ -0,0 +1,118 @@
+package mlaudit
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// feedbackMatchWindow is how far from the reported time we look for the prediction when no ID is given.
+const feedbackMatchWindow = time.Hour
+
+// minMatchRadius is how far (in degrees) from the reported location we look
+// for the prediction when no ID is given.
+const minMatchRadius = 0.01
+
+type Handler struct {
+	Repo *Repository
+	// MatchRadius covers the scheduler's grid cell: its predictions are
+	// recorded once per cell, at the cell centre.
+	MatchRadius float64
+	// Writer, when set, is searched for records that are queued but not
+	// written yet, so feedback right after a prediction doesn't miss it.
+	Writer *Writer
+}
+
+type feedbackRequest struct {
+	PredictionID  uint      `json:"prediction_id"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Timestamp     time.Time `json:"timestamp"`
+	Feeling       string    `json:"feeling"`
+	CorrectedRisk string    `json:"risk_level"`
+}
+
+// NewHandler creates the feedback handler; writer may be nil and
+// cellDegrees is the scheduler's grid cell size.
+func NewHandler(repo *Repository, writer *Writer, cellDegrees float64) *Handler {
+	return &Handler{Repo: repo, Writer: writer, MatchRadius: max(minMatchRadius, cellDegrees/2)}
+}
+
+// SubmitFeedback stores how the user felt (or the label they think is right) for a reading.
+// The reading is identified by prediction_id from /air-quality, or by location and timestamp.
+func (h *Handler) SubmitFeedback(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(uint)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req feedbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	req.Feeling = strings.ToLower(strings.TrimSpace(req.Feeling))
+	req.CorrectedRisk = strings.ToLower(strings.TrimSpace(req.CorrectedRisk))
+	if req.Feeling == "" && req.CorrectedRisk == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "feeling or risk_level is required"})
+	}
+	if req.Feeling != "" && req.Feeling != FeelingFine && req.Feeling != FeelingSymptoms {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "feeling must be 'fine' or 'symptoms'"})
+	}
+	if req.CorrectedRisk != "" && !slices.Contains(RiskLevels, req.CorrectedRisk) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "risk_level must be one of good, moderate, poor, hazardous"})
+	}
+
+	var rec *Record
+	var err error
+	switch {
+	case req.PredictionID != 0:
+		rec, err = h.Repo.FindByID(req.PredictionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) && h.Writer != nil {
+			if pending, ok := h.Writer.Pending(req.PredictionID); ok {
+				rec, err = pending, nil
+			}
+		}
+	case req.Latitude != 0 && req.Longitude != 0 && !req.Timestamp.IsZero():
+		rec, err = h.Repo.FindNearest(req.Latitude, req.Longitude, h.MatchRadius, req.Timestamp, feedbackMatchWindow)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "prediction_id or latitude, longitude and timestamp are required"})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No prediction found for this reading"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	// Başarısız tahminler eğitim setine etiketsiz satır olarak girmesin
+	if rec.Error != "" || rec.RiskLevel == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This prediction failed and cannot receive feedback"})
+	}
+
+	readingAt := req.Timestamp
+	if readingAt.IsZero() {
+		readingAt = rec.CreatedAt
+	}
+
+	fb := &Feedback{
+		UserID:        userID,
+		PredictionID:  rec.ID,
+		ReadingAt:     readingAt,
+		Latitude:      rec.Latitude,
+		Longitude:     rec.Longitude,
+		Features:      rec.Features,
+		PredictedRisk: rec.RiskLevel,
+		ModelVersion:  rec.ModelVersion,
+		Feeling:       req.Feeling,
+		CorrectedRisk: req.CorrectedRisk,
+	}
+	if err := h.Repo.CreateFeedback(fb); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Feedback recorded", "id": fb.ID})
+}
@@ -0,0 +1,19 @@
+package mlaudit
+
+import "time"
+
+// Record is a single ML call: the features that were sent, what came back and how long it took.
+type Record struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	Source       string    `gorm:"size:32" json:"source"` // api, scheduler, ...
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Features     []float64 `gorm:"type:jsonb;serializer:json" json:"features"`
+	RiskLevel    string    `gorm:"size:32" json:"risk_level"`
+	ModelVersion string    `gorm:"size:64;index" json:"model_version"`
+	LatencyMS    float64   `json:"latency_ms"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func (Record) TableName() string { return "prediction_audits" }
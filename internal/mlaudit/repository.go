@@ -0,0 +1,106 @@
+package mlaudit
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	DB *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{DB: db}
+}
+
+func (r *Repository) Create(rec *Record) error {
+	return r.DB.Create(rec).Error
+}
+
+func (r *Repository) CreateBatch(recs []Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	return r.DB.CreateInBatches(recs, 500).Error
+}
+
+// ReserveIDs takes n IDs from the prediction_audits sequence for records
+// that are inserted later (see Writer).
+func (r *Repository) ReserveIDs(n int) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Raw(`SELECT nextval(pg_get_serial_sequence('prediction_audits', 'id')) FROM generate_series(1, ?)`, n).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// FeaturesBetween returns the feature vectors of successful calls in [from, to),
+// newest first, capped at limit rows.
+func (r *Repository) FeaturesBetween(from, to time.Time, limit int) ([][]float64, error) {
+	var recs []Record
+	err := r.DB.Select("features").
+		Where("created_at >= ? AND created_at < ? AND error = ''", from, to).
+		Order("id DESC").
+		Limit(limit).
+		Find(&recs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]float64, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, rec.Features)
+	}
+	return out, nil
+}
+
+func (r *Repository) FindByID(id uint) (*Record, error) {
+	var rec Record
+	if err := r.DB.First(&rec, id).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// FindNearest returns the successful prediction closest in time to at, made
+// within radius degrees of the location and maxAge either side. Scheduler
+// predictions are stored at their grid cell's centre, so radius should cover
+// half a cell.
+func (r *Repository) FindNearest(latitude, longitude, radius float64, at time.Time, maxAge time.Duration) (*Record, error) {
+	var rec Record
+	err := r.DB.
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", latitude-radius, latitude+radius, longitude-radius, longitude+radius).
+		Where("created_at BETWEEN ? AND ? AND error = '' AND risk_level <> ''", at.Add(-maxAge), at.Add(maxAge)).
+		// First would merge its primary key ORDER BY over this expression
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "ABS(EXTRACT(EPOCH FROM (created_at - ?)))", Vars: []any{at}}}).
+		Take(&rec).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *Repository) CreateFeedback(f *Feedback) error {
+	return r.DB.Create(f).Error
+}
+
+// TrainingRow is a feedback entry joined with the prediction it refers to.
+type TrainingRow struct {
+	Feedback
+	Source      string    `json:"source"`
+	PredictedAt time.Time `json:"predicted_at"`
+	LatencyMS   float64   `json:"latency_ms"`
+}
+
+// TrainingSet returns labelled feedback created at or after since, oldest first.
+func (r *Repository) TrainingSet(since time.Time) ([]TrainingRow, error) {
+	var rows []TrainingRow
+	err := r.DB.Table("prediction_feedback AS f").
+		Select("f.*, a.source, a.created_at AS predicted_at, a.latency_ms").
+		Joins("LEFT JOIN prediction_audits AS a ON a.id = f.prediction_id").
+		Where("f.created_at >= ?", since).
+		Order("f.id").
+		Scan(&rows).Error
+	return rows, err
+}
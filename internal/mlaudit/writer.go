@@ -0,0 +1,166 @@
+package mlaudit
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	writerQueue     = 4096 // records waiting to be written; more are dropped
+	writerBatchSize = 500
+	writerFlush     = time.Second
+	writerIDBlock   = 100 // IDs reserved per sequence round trip
+	writerIDRetry   = 5 * time.Second
+)
+
+// Writer stores audit records in the background, in batches, so requests
+// don't wait for an insert. IDs are reserved from the table's sequence ahead
+// of time, in blocks, so a record's ID (the prediction_id clients send
+// feedback for) is known before the row is written. Queued records can be
+// looked up with Pending until they are flushed.
+type Writer struct {
+	repo    *Repository
+	reserve func(n int) ([]uint, error)
+	queue   chan Record
+	ids     chan uint // reserved, not yet used; refilled in the background
+	stop    chan struct{}
+	done    chan struct{}
+	pending sync.Map // uint -> Record, queued but not written yet
+
+	mu     sync.RWMutex // guards closed against sends on the closed queue
+	closed bool
+}
+
+// NewWriter starts the background writer; Close flushes it.
+func NewWriter(repo *Repository) *Writer {
+	return newWriter(repo, repo.ReserveIDs)
+}
+
+func newWriter(repo *Repository, reserve func(n int) ([]uint, error)) *Writer {
+	w := &Writer{
+		repo:    repo,
+		reserve: reserve,
+		queue:   make(chan Record, writerQueue),
+		ids:     make(chan uint, 2*writerIDBlock),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	go w.refill()
+	return w
+}
+
+// Add queues rec and returns the ID it will be stored with. It never waits
+// for the database: when no reserved ID is at hand (at startup or while the
+// sequence is unreachable) the record is stored with a database-assigned ID
+// and 0 is returned, as it is when the record was dropped.
+func (w *Writer) Add(rec Record) uint {
+	select {
+	case rec.ID = <-w.ids:
+	default:
+		rec.ID = 0
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0
+	}
+	if rec.ID != 0 {
+		w.pending.Store(rec.ID, rec)
+	}
+	select {
+	case w.queue <- rec:
+		return rec.ID
+	default:
+		// Kuyruk doluysa isteği bekletmek yerine kaydı bırak
+		w.pending.Delete(rec.ID)
+		log.Printf("prediction audit queue full; record %d dropped", rec.ID)
+		return 0
+	}
+}
+
+// Pending returns the queued record with id if it hasn't been written yet.
+func (w *Writer) Pending(id uint) (*Record, bool) {
+	v, ok := w.pending.Load(id)
+	if !ok {
+		return nil, false
+	}
+	rec := v.(Record)
+	return &rec, true
+}
+
+// Close writes the queued records and stops the writer; later records are dropped.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+// refill keeps the ID buffer topped up, one sequence round trip per block.
+func (w *Writer) refill() {
+	for {
+		ids, err := w.reserve(writerIDBlock)
+		if err != nil {
+			log.Printf("prediction audit id reservation failed: %v", err)
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(writerIDRetry):
+			}
+			continue
+		}
+		for _, id := range ids {
+			select {
+			case w.ids <- id:
+			case <-w.stop:
+				return
+			}
+		}
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(writerFlush)
+	defer ticker.Stop()
+
+	var batch []Record
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.repo.CreateBatch(batch); err != nil {
+			log.Printf("prediction audit failed for %d record(s): %v", len(batch), err)
+		}
+		for _, rec := range batch {
+			w.pending.Delete(rec.ID)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case rec, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= writerBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
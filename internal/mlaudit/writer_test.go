@@ -0,0 +1,117 @@
+package mlaudit
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRepo returns a repository on a private in-memory SQLite database.
+func newTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&Record{}, &Feedback{}); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	return NewRepository(db)
+}
+
+// sequence hands out IDs like the table's sequence (SQLite has none).
+func sequence(next uint) func(n int) ([]uint, error) {
+	return func(n int) ([]uint, error) {
+		ids := make([]uint, n)
+		for i := range ids {
+			ids[i], next = next, next+1
+		}
+		return ids, nil
+	}
+}
+
+func TestWriterAddDoesNotWaitForReservation(t *testing.T) {
+	repo := newTestRepo(t)
+	release := make(chan struct{})
+	w := newWriter(repo, func(n int) ([]uint, error) {
+		<-release // veritabanı yanıt vermiyor
+		return nil, nil
+	})
+
+	done := make(chan uint)
+	go func() { done <- w.Add(Record{Source: "api", RiskLevel: "good"}) }()
+	select {
+	case id := <-done:
+		if id != 0 {
+			t.Errorf("Add without reserved IDs = %d, want 0", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Add waited for the ID reservation")
+	}
+
+	close(release)
+	w.Close()
+	var count int64
+	repo.DB.Model(&Record{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d record(s) written, want 1 with a database-assigned ID", count)
+	}
+}
+
+func TestFeedbackForPendingRecord(t *testing.T) {
+	repo := newTestRepo(t)
+	w := newWriter(repo, sequence(1000))
+	defer w.Close()
+	for len(w.ids) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	id := w.Add(Record{Source: "api", Latitude: 41, Longitude: 29, RiskLevel: "moderate", Features: []float64{1, 2}})
+	if id != 1000 {
+		t.Fatalf("Add = %d, want the reserved ID 1000", id)
+	}
+	if _, ok := w.Pending(id); !ok {
+		t.Fatal("queued record is not pending")
+	}
+
+	h := NewHandler(repo, w, 0)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", uint(7))
+		return c.Next()
+	})
+	app.Post("/feedback", h.SubmitFeedback)
+	req := httptest.NewRequest("POST", "/feedback", strings.NewReader(`{"prediction_id":1000,"feeling":"symptoms"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("feedback before the flush: status %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+
+	w.Close()
+	if _, ok := w.Pending(id); ok {
+		t.Error("record still pending after the flush")
+	}
+	if _, err := repo.FindByID(id); err != nil {
+		t.Errorf("record %d not written: %v", id, err)
+	}
+	var fb Feedback
+	if err := repo.DB.Take(&fb).Error; err != nil || fb.PredictionID != id || fb.PredictedRisk != "moderate" {
+		t.Errorf("feedback = %+v (%v), want one for prediction %d", fb, err, id)
+	}
+}
@@ -0,0 +1,191 @@
+package mlclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchConcurrency = 8
+	defaultBatchSize        = 256
+	// batchRetryAfter is how long we stop trying the batch endpoint after the
+	// service told us it does not support it.
+	batchRetryAfter = time.Hour
+)
+
+// BatchResult is the outcome of a single request inside a batch.
+type BatchResult struct {
+	Prediction PredictionResponse
+	Err        error
+}
+
+type batchRequest struct {
+	Instances []PredictionRequest `json:"instances"`
+}
+
+type batchResponse struct {
+	Predictions []PredictionResponse `json:"predictions"`
+}
+
+// UseBatchConcurrency sets how many single requests may run in parallel when
+// the service does not support batching.
+func (c *Client) UseBatchConcurrency(n int) {
+	if n > 0 {
+		c.batchConcurrency = n
+	}
+}
+
+// UseBatchSize sets the most requests sent to the batch endpoint in one call.
+func (c *Client) UseBatchSize(n int) {
+	if n > 0 {
+		c.batchSize = n
+	}
+}
+
+// PredictBatch sends the requests to the batch endpoint in chunks of at most
+// the batch size. If the service does not expose a batch endpoint, it falls
+// back to concurrent single Predict calls. Results are returned in request
+// order; a failed chunk sets Err on its items, and the error is only set when
+// every chunk failed.
+func (c *Client) PredictBatch(reqs []PredictionRequest) ([]BatchResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	results := make([]BatchResult, 0, len(reqs))
+	chunks, failed := 0, 0
+	var lastErr error
+	for start := 0; start < len(reqs); start += c.batchSize {
+		chunk := reqs[start:min(start+c.batchSize, len(reqs))]
+		chunks++
+		part, err := c.predictChunk(chunk)
+		if err != nil {
+			// Başarısız parça diğer parçaların sonuçlarını bozmasın
+			failed, lastErr = failed+1, err
+			for range chunk {
+				results = append(results, BatchResult{Err: err})
+			}
+			continue
+		}
+		results = append(results, part...)
+	}
+	if failed == chunks {
+		return nil, lastErr
+	}
+	return results, nil
+}
+
+// predictChunk predicts one chunk in a single batch call, or with single calls
+// when batching is not supported.
+func (c *Client) predictChunk(reqs []PredictionRequest) ([]BatchResult, error) {
+	if c.batchSupported() {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		predictions, err := c.predictBatch(reqs)
+		if isBatchUnsupported(err) {
+			c.breaker.Record(nil)
+			c.markBatchUnsupported()
+			log.Printf("ml batch endpoint unavailable (%v); falling back to single predictions", err)
+		} else {
+			if countsAsFailure(err) {
+				c.breaker.Record(err)
+			} else {
+				c.breaker.Record(nil)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			results := make([]BatchResult, len(predictions))
+			for i, p := range predictions {
+				results[i] = BatchResult{Prediction: p}
+			}
+			return results, nil
+		}
+	}
+
+	return c.predictEach(reqs), nil
+}
+
+func (c *Client) predictBatch(reqs []PredictionRequest) ([]PredictionResponse, error) {
+	payload, err := json.Marshal(batchRequest{Instances: reqs})
+	if err != nil {
+		return nil, fmt.Errorf("marshal ml batch request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.batchURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("create ml batch request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ml batch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	var out batchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode ml batch response: %w", err)
+	}
+	if len(out.Predictions) != len(reqs) {
+		return nil, fmt.Errorf("ml batch response has %d predictions for %d requests", len(out.Predictions), len(reqs))
+	}
+
+	return out.Predictions, nil
+}
+
+// predictEach runs single predictions with bounded concurrency.
+func (c *Client) predictEach(reqs []PredictionRequest) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, c.batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req PredictionRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			prediction, err := c.Predict(req)
+			results[i] = BatchResult{Prediction: prediction, Err: err}
+		}(i, req)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func (c *Client) batchSupported() bool {
+	return time.Now().UnixNano() >= c.batchRetryAt.Load()
+}
+
+func (c *Client) markBatchUnsupported() {
+	c.batchRetryAt.Store(time.Now().Add(batchRetryAfter).UnixNano())
+}
+
+// isBatchUnsupported reports whether the service answered that it has no batch endpoint.
+func isBatchUnsupported(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.code {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package mlclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchService echoes each instance's "N" feature as its risk level, so
+// results can be matched to requests. batchStatus, when set, is returned by
+// the batch endpoint instead.
+type batchService struct {
+	batchStatus atomic.Int32
+	failChunk   atomic.Int32 // 1-based batch call that fails with 500; 0 for none
+
+	mu     sync.Mutex
+	chunks []int // instance count of each batch call
+	single int
+}
+
+func (s *batchService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/batch") {
+		if code := s.batchStatus.Load(); code != 0 {
+			w.WriteHeader(int(code))
+			return
+		}
+		var req batchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		s.mu.Lock()
+		s.chunks = append(s.chunks, len(req.Instances))
+		call := len(s.chunks)
+		s.mu.Unlock()
+		if int32(call) == s.failChunk.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		out := batchResponse{Predictions: make([]PredictionResponse, len(req.Instances))}
+		for i, inst := range req.Instances {
+			out.Predictions[i] = PredictionResponse{RiskLevel: fmt.Sprint(inst["N"])}
+		}
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+
+	var req PredictionRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	s.single++
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(PredictionResponse{RiskLevel: fmt.Sprint(req["N"])})
+}
+
+func newBatchClient(t *testing.T, svc *batchService) *Client {
+	t.Helper()
+	srv := httptest.NewServer(svc)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, "", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func numbered(n int) []PredictionRequest {
+	reqs := make([]PredictionRequest, n)
+	for i := range reqs {
+		reqs[i] = PredictionRequest{"N": float64(i)}
+	}
+	return reqs
+}
+
+func checkOrder(t *testing.T, results []BatchResult, n int) {
+	t.Helper()
+	if len(results) != n {
+		t.Fatalf("%d results for %d requests", len(results), n)
+	}
+	for i, r := range results {
+		if r.Err != nil || r.Prediction.RiskLevel != fmt.Sprint(i) {
+			t.Errorf("result %d = %q (%v), want %q", i, r.Prediction.RiskLevel, r.Err, fmt.Sprint(i))
+		}
+	}
+}
+
+func TestPredictBatchChunks(t *testing.T) {
+	svc := &batchService{}
+	c := newBatchClient(t, svc)
+	c.UseBatchSize(3)
+
+	results, err := c.PredictBatch(numbered(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, results, 7)
+	if fmt.Sprint(svc.chunks) != "[3 3 1]" || svc.single != 0 {
+		t.Errorf("batch calls %v and %d single call(s), want [3 3 1] and none", svc.chunks, svc.single)
+	}
+}
+
+func TestPredictBatchFailedChunk(t *testing.T) {
+	svc := &batchService{}
+	svc.failChunk.Store(2)
+	c := newBatchClient(t, svc)
+	c.UseBatchSize(2)
+
+	results, err := c.PredictBatch(numbered(5))
+	if err != nil {
+		t.Fatalf("partial failure returned %v", err)
+	}
+	for i, r := range results {
+		failed := i == 2 || i == 3 // ikinci parça
+		if (r.Err != nil) != failed {
+			t.Errorf("result %d: err %v, want failed=%v", i, r.Err, failed)
+		}
+	}
+
+	// Bütün parçalar başarısızsa hata döner
+	svc.batchStatus.Store(http.StatusBadGateway)
+	if _, err := c.PredictBatch(numbered(3)); err == nil {
+		t.Error("no error when every chunk failed")
+	}
+}
+
+func TestPredictBatchUnsupported(t *testing.T) {
+	for _, code := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			svc := &batchService{}
+			svc.batchStatus.Store(int32(code))
+			c := newBatchClient(t, svc)
+
+			results, err := c.PredictBatch(numbered(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkOrder(t, results, 4)
+			if svc.single != 4 {
+				t.Errorf("%d single call(s), want 4", svc.single)
+			}
+			if h := c.Health(); h.State != "closed" || h.ConsecutiveFailures != 0 {
+				t.Errorf("missing batch endpoint counted as a failure: %+v", h)
+			}
+
+			// Bir saat boyunca toplu uç nokta tekrar denenmez
+			svc.batchStatus.Store(0)
+			if _, err := c.PredictBatch(numbered(2)); err != nil {
+				t.Fatal(err)
+			}
+			if len(svc.chunks) != 0 || svc.single != 6 {
+				t.Errorf("batch endpoint retried within the hour: batch calls %v, %d single call(s)", svc.chunks, svc.single)
+			}
+			if retry := time.Unix(0, c.batchRetryAt.Load()); time.Until(retry) < batchRetryAfter-time.Minute {
+				t.Errorf("batch retry at %s, want about an hour from now", retry)
+			}
+
+			// Süre dolunca toplu uç nokta yeniden kullanılır
+			c.batchRetryAt.Store(time.Now().Add(-time.Second).UnixNano())
+			results, err = c.PredictBatch(numbered(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkOrder(t, results, 2)
+			if fmt.Sprint(svc.chunks) != "[2]" {
+				t.Errorf("batch calls after the retry time %v, want [2]", svc.chunks)
+			}
+		})
+	}
+}
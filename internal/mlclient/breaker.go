@@ -0,0 +1,159 @@
+package mlclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	healthWindowSize        = 50
+)
+
+// ErrCircuitOpen is returned without contacting the ML service while the breaker is open.
+var ErrCircuitOpen = errors.New("ml circuit breaker is open")
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Health is a point-in-time view of the breaker used by health endpoints.
+type Health struct {
+	State               string     `json:"state"`
+	ErrorRate           float64    `json:"error_rate"`
+	Samples             int        `json:"samples"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Breaker is a closed/open/half-open circuit breaker. After failureThreshold
+// consecutive failures it opens and rejects calls for openTimeout, then lets a
+// single probe through (half-open) to decide whether to close again.
+// It also keeps the outcome of the last calls to report a recent error rate.
+type Breaker struct {
+	mu                  sync.Mutex
+	failureThreshold    int
+	openTimeout         time.Duration
+	state               BreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	probeInFlight       bool
+	window              [healthWindowSize]bool // true = failure
+	next                int
+	samples             int
+	lastError           string
+	now                 func() time.Time
+}
+
+// NewBreaker creates a breaker. Non-positive values fall back to the defaults
+// (5 failures, 30 seconds).
+func NewBreaker(failureThreshold int, openTimeout time.Duration) *Breaker {
+	if failureThreshold <= 0 {
+		failureThreshold = defaultFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = defaultOpenTimeout
+	}
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed. It returns ErrCircuitOpen while the
+// breaker is open or while the half-open probe is still running.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probeInFlight = true
+		return nil
+	case StateHalfOpen:
+		if b.probeInFlight {
+			return ErrCircuitOpen
+		}
+		b.probeInFlight = true
+		return nil
+	}
+	return nil
+}
+
+// Record stores the outcome of a call that was allowed by Allow.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.window[b.next] = err != nil
+	b.next = (b.next + 1) % healthWindowSize
+	if b.samples < healthWindowSize {
+		b.samples++
+	}
+	b.probeInFlight = false
+
+	if err == nil {
+		b.state = StateClosed
+		b.consecutiveFailures = 0
+		return
+	}
+
+	b.lastError = err.Error()
+	b.consecutiveFailures++
+	if b.state == StateHalfOpen || b.consecutiveFailures >= b.failureThreshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Health returns the current state and error rate over the recent window.
+func (b *Breaker) Health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failures := 0
+	for i := 0; i < b.samples; i++ {
+		if b.window[i] {
+			failures++
+		}
+	}
+
+	h := Health{
+		State:               b.state.String(),
+		Samples:             b.samples,
+		ConsecutiveFailures: b.consecutiveFailures,
+		LastError:           b.lastError,
+	}
+	if b.samples > 0 {
+		h.ErrorRate = float64(failures) / float64(b.samples)
+	}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		h.OpenedAt = &openedAt
+	}
+	return h
+}
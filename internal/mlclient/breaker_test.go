@@ -0,0 +1,122 @@
+package mlclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubService answers /predict with status (200 returns a prediction) and counts the calls.
+type stubService struct {
+	status atomic.Int32
+	calls  atomic.Int32
+}
+
+func (s *stubService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.calls.Add(1)
+	if code := int(s.status.Load()); code != http.StatusOK {
+		w.WriteHeader(code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"risk_level":"good"}`))
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	svc := &stubService{}
+	srv := httptest.NewServer(svc)
+	defer srv.Close()
+
+	c, err := New(srv.URL, "", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	b := NewBreaker(3, 30*time.Second)
+	b.now = func() time.Time { return clock }
+	c.UseBreaker(b)
+
+	req := PredictionRequest{"PM2_5": 12}
+	expect := func(step string, wantErr error, wantState string) {
+		t.Helper()
+		_, err := c.Predict(req)
+		var ok bool
+		switch wantErr {
+		case nil:
+			ok = err == nil
+		case errAny:
+			ok = err != nil && !errors.Is(err, ErrCircuitOpen)
+		default:
+			ok = errors.Is(err, wantErr)
+		}
+		if !ok {
+			t.Fatalf("%s: err %v, want %v", step, err, wantErr)
+		}
+		if got := c.Health().State; got != wantState {
+			t.Fatalf("%s: state %s, want %s", step, got, wantState)
+		}
+	}
+
+	// İstemci hataları servisin sağlığı hakkında bir şey söylemez
+	svc.status.Store(http.StatusUnprocessableEntity)
+	for i := 0; i < 5; i++ {
+		expect("4xx", errAny, "closed")
+	}
+
+	svc.status.Store(http.StatusInternalServerError)
+	expect("first 5xx", errAny, "closed")
+	expect("second 5xx", errAny, "closed")
+	expect("third 5xx", errAny, "open")
+
+	calls := svc.calls.Load()
+	expect("while open", ErrCircuitOpen, "open")
+	if svc.calls.Load() != calls {
+		t.Fatal("open breaker contacted the service")
+	}
+
+	// Deneme isteği başarısız: devre tekrar açılır ve süre yeniden başlar
+	clock = clock.Add(31 * time.Second)
+	expect("failed probe", errAny, "open")
+	clock = clock.Add(10 * time.Second)
+	expect("reopened", ErrCircuitOpen, "open")
+
+	clock = clock.Add(31 * time.Second)
+	svc.status.Store(http.StatusOK)
+	expect("successful probe", nil, "closed")
+	expect("closed again", nil, "closed")
+	if h := c.Health(); h.ConsecutiveFailures != 0 || h.OpenedAt != nil {
+		t.Errorf("health after closing: %+v", h)
+	}
+}
+
+// errAny matches any error from the service itself in TestBreakerTransitions.
+var errAny = errors.New("any error")
+
+func TestBreakerSingleProbe(t *testing.T) {
+	clock := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Second)
+	b.now = func() time.Time { return clock }
+
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Record(errors.New("timeout"))
+	clock = clock.Add(2 * time.Second)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if got := b.Health().State; got != "half-open" {
+		t.Fatalf("state %s, want half-open", got)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call during the probe: %v, want ErrCircuitOpen", err)
+	}
+	b.Record(nil)
+	if err := b.Allow(); err != nil || b.Health().State != "closed" {
+		t.Fatalf("after a successful probe: %v, state %s", err, b.Health().State)
+	}
+}
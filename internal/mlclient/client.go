@@ -3,9 +3,11 @@ package mlclient
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,14 +15,20 @@ const defaultPredictPath = "/predict"
 
 // Client talks to the external ML prediction service.
 type Client struct {
-	baseURL    string
-	predictURL string
-	httpClient *http.Client
+	baseURL          string
+	predictURL       string
+	batchURL         string
+	httpClient       *http.Client
+	breaker          *Breaker
+	batchConcurrency int
+	batchSize        int
+	batchRetryAt     atomic.Int64 // unix nanos; batch endpoint is skipped until then
 }
 
 // New creates a client for the ML service.
 // baseURL should contain host (e.g. http://localhost:8000)
-// predictPath is optional and defaults to /predict.
+// predictPath is optional and defaults to /predict. Batch requests go to
+// predictPath + "/batch".
 func New(baseURL, predictPath string, httpClient *http.Client) (*Client, error) {
 	baseURL = strings.TrimSuffix(baseURL, "/")
 	if baseURL == "" {
@@ -38,38 +46,107 @@ func New(baseURL, predictPath string, httpClient *http.Client) (*Client, error)
 	}
 
 	return &Client{
-		baseURL:    baseURL,
-		predictURL: baseURL + predictPath,
-		httpClient: httpClient,
+		baseURL:          baseURL,
+		predictURL:       baseURL + predictPath,
+		batchURL:         baseURL + strings.TrimSuffix(predictPath, "/") + "/batch",
+		httpClient:       httpClient,
+		breaker:          NewBreaker(0, 0),
+		batchConcurrency: defaultBatchConcurrency,
+		batchSize:        defaultBatchSize,
 	}, nil
 }
 
-// PredictionRequest is the payload sent to the ML service.
-type PredictionRequest struct {
-	Temperature       float64 `json:"Temperature"`
-	Humidity          float64 `json:"Humidity"`
-	PM25              float64 `json:"PM2_5"`
-	PM10              float64 `json:"PM10"`
-	NO2               float64 `json:"NO2"`
-	SO2               float64 `json:"SO2"`
-	CO                float64 `json:"CO"`
-	PopulationDensity float64 `json:"Population_Density"`
+// UseBreaker replaces the default circuit breaker.
+func (c *Client) UseBreaker(b *Breaker) {
+	if b != nil {
+		c.breaker = b
+	}
+}
+
+// Health reports the circuit breaker state and recent error rate.
+func (c *Client) Health() Health {
+	return c.breaker.Health()
+}
+
+// PredictionRequest is the payload sent to the ML service: model feature
+// name (e.g. "PM2_5") -> value. Build it with a FeatureMapping.
+type PredictionRequest map[string]float64
+
+// Feature returns the value of a model feature.
+func (r PredictionRequest) Feature(name string) (float64, bool) {
+	v, ok := r[name]
+	return v, ok
 }
 
 // PredictionResponse represents the ML model result.
 type PredictionResponse struct {
 	RiskLevel string         `json:"risk_level"`
-	Meta      map[string]any `json:"meta,omitempty"`
+	Meta      PredictionMeta `json:"meta"`
+}
+
+// PredictionMeta carries the model details returned next to the risk level.
+type PredictionMeta struct {
+	Probabilities map[string]float64 `json:"probabilities,omitempty"` // class -> probability
+	ModelVersion  string             `json:"model_version,omitempty"`
+	SchemaVersion string             `json:"feature_schema_version,omitempty"`
+	// Contributions is only set for explained predictions: feature -> effect on the predicted class.
+	Contributions map[string]float64 `json:"contributions,omitempty"`
+}
+
+// Confidence returns the probability the model assigned to the predicted class.
+func (p PredictionResponse) Confidence() (float64, bool) {
+	for class, prob := range p.Meta.Probabilities {
+		if strings.EqualFold(class, p.RiskLevel) {
+			return prob, true
+		}
+	}
+	return 0, false
+}
+
+// statusError is an HTTP error status returned by the ML service.
+type statusError struct{ code int }
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("ml service error: status %d", e.code)
+}
+
+// countsAsFailure reports whether err should trip the breaker. Client-side
+// (4xx) errors say nothing about the health of the service.
+func countsAsFailure(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return err != nil
 }
 
 // Predict sends pollutant metrics to the ML service and returns the prediction.
+// While the circuit breaker is open it fails fast with ErrCircuitOpen.
 func (c *Client) Predict(req PredictionRequest) (PredictionResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return PredictionResponse{}, err
+	}
+
+	prediction, err := c.predict(req)
+	if countsAsFailure(err) {
+		c.breaker.Record(err)
+	} else {
+		c.breaker.Record(nil)
+	}
+	return prediction, err
+}
+
+func (c *Client) predict(req PredictionRequest) (PredictionResponse, error) {
+	return c.post(c.predictURL, req)
+}
+
+func (c *Client) post(url string, req PredictionRequest) (PredictionResponse, error) {
 	payload, err := json.Marshal(req)
 	if err != nil {
 		return PredictionResponse{}, fmt.Errorf("marshal ml request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, c.predictURL, bytes.NewReader(payload))
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
 		return PredictionResponse{}, fmt.Errorf("create ml request: %w", err)
 	}
@@ -82,7 +159,7 @@ func (c *Client) Predict(req PredictionRequest) (PredictionResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return PredictionResponse{}, fmt.Errorf("ml service error: status %d", resp.StatusCode)
+		return PredictionResponse{}, &statusError{code: resp.StatusCode}
 	}
 
 	var prediction PredictionResponse
@@ -0,0 +1,182 @@
+package mlclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrExplainUnsupported is returned when the ML service cannot explain predictions.
+var ErrExplainUnsupported = errors.New("ml service does not support explanations")
+
+// Explainer returns a prediction whose Meta.Contributions says how much each
+// feature pushed the model towards the predicted class.
+type Explainer interface {
+	Explain(req PredictionRequest) (PredictionResponse, error)
+}
+
+var (
+	_ Explainer = (*Client)(nil)
+	_ Explainer = (*SensitivityExplainer)(nil)
+)
+
+// Explain asks the ML service for SHAP-style contributions ({predict}?explain=true).
+func (c *Client) Explain(req PredictionRequest) (PredictionResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return PredictionResponse{}, err
+	}
+
+	prediction, err := c.post(c.predictURL+"?explain=true", req)
+	if countsAsFailure(err) {
+		c.breaker.Record(err)
+	} else {
+		c.breaker.Record(nil)
+	}
+
+	var se *statusError
+	if errors.As(err, &se) && se.code < 500 {
+		return PredictionResponse{}, ErrExplainUnsupported
+	}
+	if err != nil {
+		return PredictionResponse{}, err
+	}
+	if len(prediction.Meta.Contributions) == 0 {
+		return prediction, ErrExplainUnsupported
+	}
+	return prediction, nil
+}
+
+// SensitivityExplainer explains any Predictor by resetting one feature at a
+// time to a reference value and measuring how much the probability of the
+// predicted class drops. Models without probabilities count a class change as 1.
+type SensitivityExplainer struct {
+	predictor Predictor
+	reference PredictionRequest // feature -> reference ("clean") value; only these features are explained
+}
+
+func NewSensitivityExplainer(predictor Predictor, reference PredictionRequest) *SensitivityExplainer {
+	return &SensitivityExplainer{predictor: predictor, reference: reference}
+}
+
+func (e *SensitivityExplainer) Explain(req PredictionRequest) (PredictionResponse, error) {
+	features, perturbed := e.perturb(req)
+
+	// Orijinal istek + her özellik için bir pertürbasyon, tek batch çağrısı
+	results, err := e.predict(append([]PredictionRequest{req}, perturbed...))
+	if err != nil {
+		return PredictionResponse{}, err
+	}
+	if results[0].Err != nil {
+		return PredictionResponse{}, results[0].Err
+	}
+	return contributions(results[0].Prediction, features, results[1:]), nil
+}
+
+// ExplainPrediction explains a prediction already made for req; only the
+// perturbed requests are predicted.
+func (e *SensitivityExplainer) ExplainPrediction(req PredictionRequest, prediction PredictionResponse) (PredictionResponse, error) {
+	features, perturbed := e.perturb(req)
+	results, err := e.predict(perturbed)
+	if err != nil {
+		return PredictionResponse{}, err
+	}
+	return contributions(prediction, features, results), nil
+}
+
+// perturb returns the explained features of req (sorted) and, for each, a
+// copy of req with that feature reset to its reference.
+func (e *SensitivityExplainer) perturb(req PredictionRequest) ([]string, []PredictionRequest) {
+	features := make([]string, 0, len(e.reference))
+	for name := range e.reference {
+		if _, ok := req[name]; ok {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+
+	reqs := make([]PredictionRequest, 0, len(features))
+	for _, name := range features {
+		perturbed := make(PredictionRequest, len(req))
+		for k, v := range req {
+			perturbed[k] = v
+		}
+		perturbed[name] = e.reference[name]
+		reqs = append(reqs, perturbed)
+	}
+	return features, reqs
+}
+
+func (e *SensitivityExplainer) predict(reqs []PredictionRequest) ([]BatchResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	results, err := e.predictor.PredictBatch(reqs)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(reqs) {
+		return nil, fmt.Errorf("sensitivity explain: %d results for %d requests", len(results), len(reqs))
+	}
+	return results, nil
+}
+
+// contributions sets how much the probability of the predicted class drops
+// when each feature is reset; results[i] is the prediction without features[i].
+func contributions(prediction PredictionResponse, features []string, results []BatchResult) PredictionResponse {
+	base := classScore(prediction, prediction.RiskLevel)
+	prediction.Meta.Contributions = make(map[string]float64, len(features))
+	for i, name := range features {
+		r := results[i]
+		if r.Err != nil {
+			continue
+		}
+		prediction.Meta.Contributions[name] = base - classScore(r.Prediction, prediction.RiskLevel)
+	}
+	return prediction
+}
+
+// classScore is the probability of class, or 1/0 when the model reports no probabilities.
+func classScore(p PredictionResponse, class string) float64 {
+	if len(p.Meta.Probabilities) > 0 {
+		for c, prob := range p.Meta.Probabilities {
+			if strings.EqualFold(c, class) {
+				return prob
+			}
+		}
+		return 0
+	}
+	if strings.EqualFold(p.RiskLevel, class) {
+		return 1
+	}
+	return 0
+}
+
+// TopContributions returns the features with the largest positive contribution, largest first.
+func (p PredictionResponse) TopContributions(n int) []string {
+	type kv struct {
+		name  string
+		value float64
+	}
+	var all []kv
+	for name, v := range p.Meta.Contributions {
+		if v > 0 {
+			all = append(all, kv{name, v})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].value == all[j].value {
+			return all[i].name < all[j].name
+		}
+		return all[i].value > all[j].value
+	})
+
+	if len(all) > n {
+		all = all[:n]
+	}
+	out := make([]string, len(all))
+	for i, c := range all {
+		out[i] = c.name
+	}
+	return out
+}
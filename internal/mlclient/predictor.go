@@ -0,0 +1,9 @@
+package mlclient
+
+// Predictor is implemented by every prediction backend.
+type Predictor interface {
+	Predict(req PredictionRequest) (PredictionResponse, error)
+	PredictBatch(reqs []PredictionRequest) ([]BatchResult, error)
+}
+
+var _ Predictor = (*Client)(nil)
@@ -0,0 +1,181 @@
+package mlclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultSchemaPath = "/schema"
+
+// ErrSchemaUnavailable is returned when the ML service does not publish a schema.
+var ErrSchemaUnavailable = errors.New("ml service does not expose a feature schema")
+
+// Schema lists the features a model expects.
+type Schema struct {
+	Version  string          `json:"version"`
+	Features []SchemaFeature `json:"features"`
+}
+
+// SchemaFeature is a single model input. It decodes from either a plain name
+// ("PM2_5", required) or an object ({"name": "PM2_5", "required": false}).
+type SchemaFeature struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+func (f *SchemaFeature) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*f = SchemaFeature{Name: name, Required: true}
+		return nil
+	}
+
+	var obj struct {
+		Name     string `json:"name"`
+		Required *bool  `json:"required"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*f = SchemaFeature{Name: obj.Name, Required: obj.Required == nil || *obj.Required}
+	return nil
+}
+
+// FetchSchema downloads the feature schema from {baseURL}/schema.
+func (c *Client) FetchSchema() (Schema, error) {
+	resp, err := c.httpClient.Get(c.baseURL + defaultSchemaPath)
+	if err != nil {
+		return Schema{}, fmt.Errorf("ml schema request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Schema{}, ErrSchemaUnavailable
+	}
+	if resp.StatusCode >= 400 {
+		return Schema{}, &statusError{code: resp.StatusCode}
+	}
+
+	var schema Schema
+	if err := json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		return Schema{}, fmt.Errorf("decode ml schema: %w", err)
+	}
+	if len(schema.Features) == 0 {
+		return Schema{}, errors.New("ml schema lists no features")
+	}
+	return schema, nil
+}
+
+// FeatureBinding says which source metric feeds a model feature.
+type FeatureBinding struct {
+	Feature string // name the model expects, e.g. "PM2_5"
+	Source  string // metric name on our side, e.g. "pm2_5"
+}
+
+// FeatureMapping is the declarative mapping used to build prediction requests.
+type FeatureMapping []FeatureBinding
+
+// DefaultFeatureMapping matches the feature names of the current ML service.
+var DefaultFeatureMapping = FeatureMapping{
+	{Feature: "Temperature", Source: "temperature"},
+	{Feature: "Humidity", Source: "humidity"},
+	{Feature: "PM2_5", Source: "pm2_5"},
+	{Feature: "PM10", Source: "pm10"},
+	{Feature: "NO2", Source: "no2"},
+	{Feature: "SO2", Source: "so2"},
+	{Feature: "CO", Source: "co"},
+	{Feature: "Population_Density", Source: "population_density"},
+}
+
+// ParseFeatureMapping parses "Feature=source,Feature=source".
+func ParseFeatureMapping(s string) (FeatureMapping, error) {
+	var mapping FeatureMapping
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		feature, source, ok := strings.Cut(pair, "=")
+		feature, source = strings.TrimSpace(feature), strings.TrimSpace(source)
+		if !ok || feature == "" || source == "" {
+			return nil, fmt.Errorf("invalid feature mapping entry %q", pair)
+		}
+		mapping = append(mapping, FeatureBinding{Feature: feature, Source: source})
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("feature mapping is empty")
+	}
+	return mapping, nil
+}
+
+// Build creates a request from source metric values. Every bound source must be present.
+func (fm FeatureMapping) Build(source map[string]float64) (PredictionRequest, error) {
+	req := make(PredictionRequest, len(fm))
+	for _, b := range fm {
+		v, ok := source[b.Source]
+		if !ok {
+			return nil, fmt.Errorf("metric %q for feature %q is not available", b.Source, b.Feature)
+		}
+		req[b.Feature] = v
+	}
+	return req, nil
+}
+
+// SchemaMismatchError describes why a mapping cannot serve a schema.
+type SchemaMismatchError struct {
+	SchemaVersion string
+	Missing       []string // required features without a binding
+	Unavailable   []string // bound features whose source metric we cannot supply
+	Unused        []string // bound features the schema does not know (informational)
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "unmapped required features: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unavailable) > 0 {
+		parts = append(parts, "unavailable source metrics: "+strings.Join(e.Unavailable, ", "))
+	}
+	return fmt.Sprintf("ml feature schema %q mismatch: %s", e.SchemaVersion, strings.Join(parts, "; "))
+}
+
+// Check validates that every required schema feature is mapped to a metric in
+// available. Features mapped but unknown to the schema are reported in Unused
+// but do not fail the check.
+func (s Schema) Check(mapping FeatureMapping, available []string) (unused []string, err error) {
+	canSupply := make(map[string]bool, len(available))
+	for _, name := range available {
+		canSupply[name] = true
+	}
+	bound := make(map[string]FeatureBinding, len(mapping))
+	for _, b := range mapping {
+		bound[b.Feature] = b
+	}
+
+	mismatch := &SchemaMismatchError{SchemaVersion: s.Version}
+	known := make(map[string]bool, len(s.Features))
+	for _, f := range s.Features {
+		known[f.Name] = true
+		b, ok := bound[f.Name]
+		switch {
+		case !ok && f.Required:
+			mismatch.Missing = append(mismatch.Missing, f.Name)
+		case ok && !canSupply[b.Source]:
+			mismatch.Unavailable = append(mismatch.Unavailable, f.Name)
+		}
+	}
+	for _, b := range mapping {
+		if !known[b.Feature] {
+			mismatch.Unused = append(mismatch.Unused, b.Feature)
+		}
+	}
+
+	if len(mismatch.Missing) > 0 || len(mismatch.Unavailable) > 0 {
+		return mismatch.Unused, mismatch
+	}
+	return mismatch.Unused, nil
+}
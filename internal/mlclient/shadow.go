@@ -0,0 +1,206 @@
+package mlclient
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultShadowInFlight = 16
+
+// Shadow serves every request from the primary predictor and replays it against
+// a candidate in the background. The candidate result is only compared and
+// counted; it never reaches the caller.
+type Shadow struct {
+	primary   Predictor
+	candidate Predictor
+	inFlight  chan struct{}
+
+	mu              sync.Mutex
+	since           time.Time
+	matrix          map[string]map[string]int // primary class -> candidate class -> count
+	candidateErrors int
+	dropped         int
+}
+
+var _ Predictor = (*Shadow)(nil)
+
+// NewShadow wraps primary with a shadow candidate. maxInFlight bounds the number
+// of concurrent candidate calls; comparisons beyond it are dropped.
+func NewShadow(primary, candidate Predictor, maxInFlight int) *Shadow {
+	if maxInFlight <= 0 {
+		maxInFlight = defaultShadowInFlight
+	}
+	return &Shadow{
+		primary:   primary,
+		candidate: candidate,
+		inFlight:  make(chan struct{}, maxInFlight),
+		since:     time.Now().UTC(),
+		matrix:    map[string]map[string]int{},
+	}
+}
+
+// Predict returns the primary prediction and compares the candidate asynchronously.
+func (s *Shadow) Predict(req PredictionRequest) (PredictionResponse, error) {
+	prediction, err := s.primary.Predict(req)
+	if err != nil {
+		return prediction, err
+	}
+
+	s.async(func() {
+		candidate, err := s.candidate.Predict(req)
+		if err != nil {
+			s.recordCandidateError(err)
+			return
+		}
+		s.compare(prediction, candidate)
+	})
+	return prediction, nil
+}
+
+// PredictBatch returns the primary results and compares the candidate batch asynchronously.
+func (s *Shadow) PredictBatch(reqs []PredictionRequest) ([]BatchResult, error) {
+	results, err := s.primary.PredictBatch(reqs)
+	if err != nil {
+		return results, err
+	}
+
+	s.async(func() {
+		candidates, err := s.candidate.PredictBatch(reqs)
+		if err != nil {
+			s.recordCandidateError(err)
+			return
+		}
+		for i := range results {
+			if i >= len(candidates) || results[i].Err != nil {
+				continue
+			}
+			if candidates[i].Err != nil {
+				s.recordCandidateError(candidates[i].Err)
+				continue
+			}
+			s.compare(results[i].Prediction, candidates[i].Prediction)
+		}
+	})
+	return results, nil
+}
+
+// async runs fn in the background unless too many candidate calls are already running.
+func (s *Shadow) async(fn func()) {
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+		return
+	}
+
+	go func() {
+		defer func() { <-s.inFlight }()
+		fn()
+	}()
+}
+
+func (s *Shadow) compare(primary, candidate PredictionResponse) {
+	p := normalizeClass(primary.RiskLevel)
+	c := normalizeClass(candidate.RiskLevel)
+
+	s.mu.Lock()
+	row, ok := s.matrix[p]
+	if !ok {
+		row = map[string]int{}
+		s.matrix[p] = row
+	}
+	row[c]++
+	s.mu.Unlock()
+
+	// Uyuşmalar yalnızca matriste sayılır; log'a sadece ayrışmalar düşer
+	if p != c {
+		log.Printf("ML shadow disagree: primary=%s candidate=%s primary_model=%s candidate_model=%s", p, c, primary.Meta.ModelVersion, candidate.Meta.ModelVersion)
+	}
+}
+
+func (s *Shadow) recordCandidateError(err error) {
+	s.mu.Lock()
+	s.candidateErrors++
+	s.mu.Unlock()
+	log.Printf("ML shadow candidate error: %v", err)
+}
+
+func normalizeClass(class string) string {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if class == "" {
+		return "unknown"
+	}
+	return class
+}
+
+// ShadowReport summarises primary/candidate agreement since the shadow started.
+type ShadowReport struct {
+	Since           time.Time                 `json:"since"`
+	Compared        int                       `json:"compared"`
+	Agreements      int                       `json:"agreements"`
+	AgreementRate   float64                   `json:"agreement_rate"`
+	CandidateErrors int                       `json:"candidate_errors"`
+	Dropped         int                       `json:"dropped"`
+	Classes         []string                  `json:"classes"`
+	PerClass        map[string]ClassAgreement `json:"per_class"`
+	// ConfusionMatrix is indexed as [primary class][candidate class].
+	ConfusionMatrix map[string]map[string]int `json:"confusion_matrix"`
+}
+
+// ClassAgreement counts comparisons for one primary class.
+type ClassAgreement struct {
+	Agree         int     `json:"agree"`
+	Disagree      int     `json:"disagree"`
+	AgreementRate float64 `json:"agreement_rate"`
+}
+
+// Report returns a snapshot of the comparison counters.
+func (s *Shadow) Report() ShadowReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := ShadowReport{
+		Since:           s.since,
+		CandidateErrors: s.candidateErrors,
+		Dropped:         s.dropped,
+		PerClass:        map[string]ClassAgreement{},
+		ConfusionMatrix: map[string]map[string]int{},
+	}
+
+	classes := map[string]bool{}
+	for p, row := range s.matrix {
+		classes[p] = true
+		copied := make(map[string]int, len(row))
+		stats := ClassAgreement{}
+		for c, count := range row {
+			classes[c] = true
+			copied[c] = count
+			report.Compared += count
+			if p == c {
+				stats.Agree += count
+			} else {
+				stats.Disagree += count
+			}
+		}
+		if total := stats.Agree + stats.Disagree; total > 0 {
+			stats.AgreementRate = float64(stats.Agree) / float64(total)
+		}
+		report.Agreements += stats.Agree
+		report.PerClass[p] = stats
+		report.ConfusionMatrix[p] = copied
+	}
+	if report.Compared > 0 {
+		report.AgreementRate = float64(report.Agreements) / float64(report.Compared)
+	}
+
+	for class := range classes {
+		report.Classes = append(report.Classes, class)
+	}
+	sort.Strings(report.Classes)
+	return report
+}
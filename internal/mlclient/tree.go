@@ -0,0 +1,480 @@
+package mlclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TreeModel evaluates an exported gradient-boosted or random-forest model in
+// process. It implements Predictor so it can replace the HTTP Client.
+//
+// The model file wraps the raw dump produced by the training library:
+//
+//	{
+//	  "format": "xgboost",             // or "lightgbm"
+//	  "objective": "multi:softprob",   // xgboost only: multi:softprob, binary:logistic or random_forest
+//	  "num_parallel_tree": 1,          // xgboost only: trees per class and round (random forests)
+//	  "version": "gbt-2025-10-01",
+//	  "schema_version": "1",
+//	  "classes": ["good", "moderate", "poor", "hazardous"],
+//	  "features": ["Temperature", "Humidity", "PM2_5", ...], // xgboost only, in training order
+//	  "base_score": 0.5,
+//	  "model": <booster.get_dump(dump_format="json") or booster.dump_model()>
+//	}
+type TreeModel struct {
+	version       string
+	schemaVersion string
+	classes       []string
+	features      []string // request feature name per model feature index
+	link          string   // softmax (one score per class) or logistic (one score)
+	average       bool     // LightGBM random forest: scores are averaged over iterations
+	parallel      int      // consecutive trees of one class in a round (XGBoost num_parallel_tree)
+	sigmoid       float64  // LightGBM binary sigmoid parameter
+	baseScore     float64
+	trees         [][]treeNode
+}
+
+var _ Predictor = (*TreeModel)(nil)
+
+type treeNode struct {
+	leaf        bool
+	value       float64
+	feature     int
+	threshold   float64
+	inclusive   bool // x <= threshold goes left (LightGBM); otherwise x < threshold (XGBoost)
+	defaultLeft bool // branch taken for missing (NaN) values
+	left, right int
+}
+
+type treeModelFile struct {
+	Format        string          `json:"format"`
+	Objective     string          `json:"objective"`
+	Version       string          `json:"version"`
+	SchemaVersion string          `json:"schema_version"`
+	Classes       []string        `json:"classes"`
+	Features      []string        `json:"features"`
+	BaseScore     float64         `json:"base_score"`
+	NumParallel   int             `json:"num_parallel_tree"`
+	Model         json.RawMessage `json:"model"`
+}
+
+// LoadTreeModel reads a model file from disk.
+func LoadTreeModel(path string) (*TreeModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tree model: %w", err)
+	}
+	return ParseTreeModel(data)
+}
+
+// ParseTreeModel parses a model file. Use Schema to check its features against
+// the feature mapping.
+func ParseTreeModel(data []byte) (*TreeModel, error) {
+	var file treeModelFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode tree model: %w", err)
+	}
+	if len(file.Classes) == 0 {
+		return nil, errors.New("tree model has no classes")
+	}
+
+	m := &TreeModel{
+		version:       file.Version,
+		schemaVersion: file.SchemaVersion,
+		classes:       file.Classes,
+		baseScore:     file.BaseScore,
+		parallel:      1,
+		sigmoid:       1,
+	}
+
+	var err error
+	switch strings.ToLower(file.Format) {
+	case "xgboost", "":
+		err = m.loadXGBoost(file)
+	case "lightgbm":
+		err = m.loadLightGBM(file)
+	default:
+		err = fmt.Errorf("unsupported tree model format %q", file.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.trees) == 0 {
+		return nil, errors.New("tree model has no trees")
+	}
+	if m.link == "logistic" && len(m.classes) != 2 {
+		return nil, errors.New("binary tree model needs exactly two classes")
+	}
+	// Ağaçlar tur başına grup sayısı kadar; eksik tur sınıfları kaydırır
+	if perRound := m.groups() * m.parallel; len(m.trees)%perRound != 0 {
+		return nil, fmt.Errorf("tree model has %d trees, not a multiple of %d per round", len(m.trees), perRound)
+	}
+	return m, nil
+}
+
+// Schema lists the features the model reads; all of them are required.
+func (m *TreeModel) Schema() Schema {
+	schema := Schema{Version: m.schemaVersion}
+	for _, name := range m.features {
+		schema.Features = append(schema.Features, SchemaFeature{Name: name, Required: true})
+	}
+	return schema
+}
+
+/* ------------ XGBoost ------------ */
+
+type xgbNode struct {
+	NodeID         int       `json:"nodeid"`
+	Split          string    `json:"split"`
+	SplitCondition float64   `json:"split_condition"`
+	Yes            int       `json:"yes"`
+	No             int       `json:"no"`
+	Missing        int       `json:"missing"`
+	Leaf           *float64  `json:"leaf"`
+	Children       []xgbNode `json:"children"`
+}
+
+func (m *TreeModel) loadXGBoost(file treeModelFile) error {
+	switch file.Objective {
+	case "multi:softprob", "multi:softmax", "":
+		m.link = "softmax"
+	case "binary:logistic":
+		m.link = "logistic"
+	case "random_forest":
+		// XGBRFClassifier: binary:logistic for two classes, multi:softprob
+		// otherwise; the parallel trees are summed like boosted ones
+		m.link = "softmax"
+		if len(m.classes) == 2 {
+			m.link = "logistic"
+		}
+	default:
+		return fmt.Errorf("unsupported xgboost objective %q", file.Objective)
+	}
+	if file.NumParallel > 1 {
+		m.parallel = file.NumParallel
+	}
+	if len(file.Features) == 0 {
+		return errors.New("xgboost tree model needs a features list")
+	}
+	m.features = file.Features
+
+	// get_dump returns one JSON document per tree; accept both decoded and string form
+	var roots []xgbNode
+	if err := json.Unmarshal(file.Model, &roots); err != nil {
+		var dumps []string
+		if err2 := json.Unmarshal(file.Model, &dumps); err2 != nil {
+			return fmt.Errorf("decode xgboost dump: %w", err)
+		}
+		roots = make([]xgbNode, len(dumps))
+		for i, d := range dumps {
+			if err := json.Unmarshal([]byte(d), &roots[i]); err != nil {
+				return fmt.Errorf("decode xgboost tree %d: %w", i, err)
+			}
+		}
+	}
+
+	for i, root := range roots {
+		nodes, err := m.flattenXGBoost(root)
+		if err != nil {
+			return fmt.Errorf("xgboost tree %d: %w", i, err)
+		}
+		m.trees = append(m.trees, nodes)
+	}
+	return nil
+}
+
+// flattenXGBoost turns the nested dump into a slice, translating node ids to slice positions.
+func (m *TreeModel) flattenXGBoost(root xgbNode) ([]treeNode, error) {
+	byID := map[int]xgbNode{}
+	var collect func(n xgbNode)
+	collect = func(n xgbNode) {
+		byID[n.NodeID] = n
+		for _, c := range n.Children {
+			collect(c)
+		}
+	}
+	collect(root)
+
+	index := map[int]int{}
+	order := []int{}
+	var visit func(id int) error
+	visit = func(id int) error {
+		n, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("missing node %d", id)
+		}
+		index[id] = len(order)
+		order = append(order, id)
+		if n.Leaf == nil {
+			if err := visit(n.Yes); err != nil {
+				return err
+			}
+			return visit(n.No)
+		}
+		return nil
+	}
+	if err := visit(root.NodeID); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]treeNode, len(order))
+	for i, id := range order {
+		n := byID[id]
+		if n.Leaf != nil {
+			nodes[i] = treeNode{leaf: true, value: *n.Leaf}
+			continue
+		}
+		feature, err := m.xgbFeature(n.Split)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = treeNode{
+			feature:     feature,
+			threshold:   n.SplitCondition,
+			defaultLeft: n.Missing == n.Yes,
+			left:        index[n.Yes],
+			right:       index[n.No],
+		}
+	}
+	return nodes, nil
+}
+
+// xgbFeature resolves a split name, which is either a feature name or "f<index>".
+func (m *TreeModel) xgbFeature(split string) (int, error) {
+	for i, name := range m.features {
+		if name == split {
+			return i, nil
+		}
+	}
+	if strings.HasPrefix(split, "f") {
+		if i, err := strconv.Atoi(split[1:]); err == nil && i >= 0 && i < len(m.features) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown split feature %q", split)
+}
+
+/* ------------ LightGBM ------------ */
+
+type lgbDump struct {
+	Objective     string   `json:"objective"`
+	NumClass      int      `json:"num_class"`
+	FeatureNames  []string `json:"feature_names"`
+	AverageOutput bool     `json:"average_output"`
+	TreeInfo      []struct {
+		TreeStructure lgbNode `json:"tree_structure"`
+	} `json:"tree_info"`
+}
+
+type lgbNode struct {
+	SplitFeature *int     `json:"split_feature"`
+	Threshold    float64  `json:"threshold"`
+	DecisionType string   `json:"decision_type"`
+	DefaultLeft  bool     `json:"default_left"`
+	LeafValue    float64  `json:"leaf_value"`
+	LeftChild    *lgbNode `json:"left_child"`
+	RightChild   *lgbNode `json:"right_child"`
+}
+
+func (m *TreeModel) loadLightGBM(file treeModelFile) error {
+	var dump lgbDump
+	if err := json.Unmarshal(file.Model, &dump); err != nil {
+		return fmt.Errorf("decode lightgbm dump: %w", err)
+	}
+
+	// e.g. "binary sigmoid:1" or "multiclass num_class:4"
+	objective := strings.Fields(dump.Objective)
+	switch {
+	case len(objective) > 0 && objective[0] == "binary":
+		m.link = "logistic"
+		for _, param := range objective[1:] {
+			if v, ok := strings.CutPrefix(param, "sigmoid:"); ok {
+				sigmoid, err := strconv.ParseFloat(v, 64)
+				if err != nil || sigmoid <= 0 {
+					return fmt.Errorf("invalid lightgbm sigmoid %q", v)
+				}
+				m.sigmoid = sigmoid
+			}
+		}
+	case len(objective) > 0 && objective[0] == "multiclass":
+		m.link = "softmax"
+	default:
+		return fmt.Errorf("unsupported lightgbm objective %q", dump.Objective)
+	}
+	m.average = dump.AverageOutput
+	if dump.NumClass > 1 && dump.NumClass != len(m.classes) {
+		return fmt.Errorf("lightgbm model has %d classes, file lists %d", dump.NumClass, len(m.classes))
+	}
+
+	m.features = dump.FeatureNames
+	if len(file.Features) > 0 {
+		m.features = file.Features
+	}
+
+	for i, info := range dump.TreeInfo {
+		var nodes []treeNode
+		var walk func(n *lgbNode) (int, error)
+		walk = func(n *lgbNode) (int, error) {
+			pos := len(nodes)
+			if n.SplitFeature == nil {
+				nodes = append(nodes, treeNode{leaf: true, value: n.LeafValue})
+				return pos, nil
+			}
+			if n.DecisionType != "<=" || n.LeftChild == nil || n.RightChild == nil {
+				return 0, fmt.Errorf("unsupported split %q", n.DecisionType)
+			}
+			if *n.SplitFeature < 0 || *n.SplitFeature >= len(m.features) {
+				return 0, fmt.Errorf("unknown split feature %d", *n.SplitFeature)
+			}
+			nodes = append(nodes, treeNode{
+				feature:     *n.SplitFeature,
+				threshold:   n.Threshold,
+				inclusive:   true,
+				defaultLeft: n.DefaultLeft,
+			})
+			left, err := walk(n.LeftChild)
+			if err != nil {
+				return 0, err
+			}
+			right, err := walk(n.RightChild)
+			if err != nil {
+				return 0, err
+			}
+			nodes[pos].left, nodes[pos].right = left, right
+			return pos, nil
+		}
+		if _, err := walk(&info.TreeStructure); err != nil {
+			return fmt.Errorf("lightgbm tree %d: %w", i, err)
+		}
+		m.trees = append(m.trees, nodes)
+	}
+	return nil
+}
+
+/* ------------ Inference ------------ */
+
+// Predict evaluates the ensemble for a single request.
+func (m *TreeModel) Predict(req PredictionRequest) (PredictionResponse, error) {
+	x := make([]float64, len(m.features))
+	for i, name := range m.features {
+		v, ok := req.Feature(name)
+		if !ok {
+			return PredictionResponse{}, fmt.Errorf("tree model feature %q missing from request", name)
+		}
+		x[i] = v
+	}
+
+	probs := m.probabilities(x)
+	best := 0
+	for i, p := range probs {
+		if p > probs[best] {
+			best = i
+		}
+	}
+
+	byClass := make(map[string]float64, len(m.classes))
+	for i, class := range m.classes {
+		byClass[class] = probs[i]
+	}
+	return PredictionResponse{
+		RiskLevel: m.classes[best],
+		Meta: PredictionMeta{
+			Probabilities: byClass,
+			ModelVersion:  m.version,
+			SchemaVersion: m.schemaVersion,
+		},
+	}, nil
+}
+
+// PredictBatch evaluates every request in process.
+func (m *TreeModel) PredictBatch(reqs []PredictionRequest) ([]BatchResult, error) {
+	results := make([]BatchResult, len(reqs))
+	for i, req := range reqs {
+		results[i].Prediction, results[i].Err = m.Predict(req)
+	}
+	return results, nil
+}
+
+// groups is the number of trees per boosting round (ignoring parallel trees).
+func (m *TreeModel) groups() int {
+	if m.link == "logistic" {
+		return 1
+	}
+	return len(m.classes)
+}
+
+// probabilities returns one probability per class. Both libraries store the
+// trees of a round class by class (the parallel trees of a class next to each
+// other), so tree i belongs to class (i / parallel) % groups. Scores are
+// summed, or averaged for LightGBM random forests, and then go through the
+// objective's link function.
+func (m *TreeModel) probabilities(x []float64) []float64 {
+	groups := m.groups()
+	scores := make([]float64, groups)
+	for i, tree := range m.trees {
+		scores[(i/m.parallel)%groups] += evalTree(tree, x)
+	}
+	if m.average {
+		rounds := float64(len(m.trees) / (groups * m.parallel))
+		for i := range scores {
+			scores[i] /= rounds
+		}
+	}
+
+	if m.link == "logistic" {
+		p := 1 / (1 + math.Exp(-m.sigmoid*(scores[0]+logit(m.baseScore))))
+		return []float64{1 - p, p}
+	}
+
+	max := math.Inf(-1)
+	for i := range scores {
+		scores[i] += m.baseScore
+		max = math.Max(max, scores[i])
+	}
+	var total float64
+	for i := range scores {
+		scores[i] = math.Exp(scores[i] - max)
+		total += scores[i]
+	}
+	for i := range scores {
+		scores[i] /= total
+	}
+	return scores
+}
+
+func evalTree(nodes []treeNode, x []float64) float64 {
+	i := 0
+	for !nodes[i].leaf {
+		n := nodes[i]
+		v := x[n.feature]
+		var goLeft bool
+		switch {
+		case math.IsNaN(v):
+			goLeft = n.defaultLeft
+		case n.inclusive:
+			goLeft = v <= n.threshold
+		default:
+			goLeft = v < n.threshold
+		}
+		if goLeft {
+			i = n.left
+		} else {
+			i = n.right
+		}
+	}
+	return nodes[i].value
+}
+
+// logit converts XGBoost's probability-space base_score to a margin.
+func logit(p float64) float64 {
+	if p <= 0 || p >= 1 {
+		return 0
+	}
+	return math.Log(p / (1 - p))
+}
@@ -0,0 +1,201 @@
+package mlclient
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// The models below are stumps on PM2_5 (split at 25) and NO2 (split at 30)
+// written in the dump formats of the libraries. The expected probabilities
+// follow how XGBoost and LightGBM score those dumps: trees are stored round
+// by round and class by class, XGBoost splits on x < threshold and LightGBM
+// on x <= threshold, XGBoost sums parallel (random forest) trees and
+// LightGBM averages average_output models over iterations before the link
+// function (sigmoid, or softmax across classes).
+
+var treeInputs = []PredictionRequest{
+	{"PM2_5": 40, "NO2": 10},
+	{"PM2_5": 10, "NO2": 60},
+	{"PM2_5": 25, "NO2": 30}, // on both thresholds
+}
+
+// xgbStump is one tree of booster.get_dump(dump_format="json").
+func xgbStump(feature string, threshold, yes, no float64) string {
+	return fmt.Sprintf(`{"nodeid":0,"split":%q,"split_condition":%g,"yes":1,"no":2,"missing":1,`+
+		`"children":[{"nodeid":1,"leaf":%g},{"nodeid":2,"leaf":%g}]}`, feature, threshold, yes, no)
+}
+
+// lgbStump is one tree_info entry of booster.dump_model().
+func lgbStump(feature int, threshold, left, right float64) string {
+	return fmt.Sprintf(`{"tree_structure":{"split_feature":%d,"threshold":%g,"decision_type":"<=","default_left":true,`+
+		`"left_child":{"leaf_value":%g},"right_child":{"leaf_value":%g}}}`, feature, threshold, left, right)
+}
+
+var (
+	// three classes, two rounds (or one round of two parallel trees per class)
+	xgbMulti = []string{
+		xgbStump("PM2_5", 25, -0.2, 0.3), xgbStump("NO2", 30, 0.1, -0.1), xgbStump("PM2_5", 25, 0.25, -0.15),
+		xgbStump("NO2", 30, -0.05, 0.2), xgbStump("PM2_5", 25, 0.1, -0.3), xgbStump("NO2", 30, 0.15, 0.05),
+	}
+	lgbMulti = []string{
+		lgbStump(0, 25, -0.2, 0.3), lgbStump(1, 30, 0.1, -0.1), lgbStump(0, 25, 0.25, -0.15),
+		lgbStump(1, 30, -0.05, 0.2), lgbStump(0, 25, 0.1, -0.3), lgbStump(1, 30, 0.15, 0.05),
+	}
+)
+
+func xgbModel(objective, extra string, classes int, trees ...string) string {
+	return fmt.Sprintf(`{"format":"xgboost","objective":%q,%s"classes":%s,"features":["PM2_5","NO2"],"model":[%s]}`,
+		objective, extra, testClasses(classes), strings.Join(trees, ","))
+}
+
+func lgbModel(objective, extra string, classes int, trees ...string) string {
+	return fmt.Sprintf(`{"format":"lightgbm","classes":%s,"model":{"objective":%q,%s"feature_names":["PM2_5","NO2"],"tree_info":[%s]}}`,
+		testClasses(classes), objective, extra, strings.Join(trees, ","))
+}
+
+func testClasses(n int) string {
+	if n == 2 {
+		return `["good","hazardous"]`
+	}
+	return `["good","moderate","poor"]`
+}
+
+func TestTreeModelProbabilities(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  [][]float64 // per input, per class
+	}{
+		{
+			name:  "xgboost multi:softprob",
+			model: xgbModel("multi:softprob", `"base_score":0.5,`, 3, xgbMulti...),
+			want: [][]float64{
+				{0.413833813046512, 0.263872089291438, 0.322294097662049},
+				{0.298520044408562, 0.298520044408562, 0.402959911182877},
+				{0.511409208081417, 0.207923467717871, 0.280667324200713},
+			},
+		},
+		{
+			name: "xgboost binary:logistic",
+			model: xgbModel("binary:logistic", `"base_score":0.3,`, 2,
+				xgbStump("PM2_5", 25, -0.4, 0.6), xgbStump("NO2", 30, -0.2, 0.5)),
+			want: [][]float64{
+				{0.609996584308133, 0.390003415691867},
+				{0.678589631633352, 0.321410368366649},
+				{0.437158522586222, 0.562841477413778},
+			},
+		},
+		{
+			name: "xgboost random_forest binary",
+			model: xgbModel("random_forest", `"num_parallel_tree":3,"base_score":0.5,`, 2,
+				xgbStump("PM2_5", 25, -0.3, 0.4), xgbStump("NO2", 30, -0.1, 0.35), xgbStump("PM2_5", 25, -0.2, 0.2)),
+			want: [][]float64{
+				{0.377540668798145, 0.622459331201855},
+				{0.53742984534375, 0.46257015465625},
+				{0.278884821977137, 0.721115178022863},
+			},
+		},
+		{
+			name:  "xgboost random_forest multiclass",
+			model: xgbModel("random_forest", `"num_parallel_tree":2,"base_score":0.5,`, 3, xgbMulti...),
+			want: [][]float64{
+				{0.470419670870623, 0.25817178922132, 0.271408539908057},
+				{0.213432948940133, 0.451837556451955, 0.334729494607912},
+				{0.400266396812581, 0.344512480431506, 0.255221122755913},
+			},
+		},
+		{
+			name: "lightgbm binary",
+			model: lgbModel("binary sigmoid:1.5", "", 2,
+				lgbStump(0, 25, -0.5, 0.7), lgbStump(1, 30, -0.3, 0.4)),
+			want: [][]float64{
+				{0.354343693774205, 0.645656306225795},
+				{0.53742984534375, 0.46257015465625},
+				{0.768524783499018, 0.231475216500982},
+			},
+		},
+		{
+			name:  "lightgbm multiclass",
+			model: lgbModel("multiclass num_class:3", `"num_class":3,`, 3, lgbMulti...),
+			want: [][]float64{
+				{0.413833813046512, 0.263872089291438, 0.322294097662049},
+				{0.298520044408562, 0.298520044408562, 0.402959911182877},
+				{0.223022475706779, 0.349768866289126, 0.427208658004095},
+			},
+		},
+		{
+			name: "lightgbm random forest binary",
+			model: lgbModel("binary sigmoid:1", `"average_output":true,`, 2,
+				lgbStump(0, 25, -0.6, 0.8), lgbStump(1, 30, -0.4, 0.5), lgbStump(0, 25, -0.2, 0.9)),
+			want: [][]float64{
+				{0.393330643891789, 0.606669356108211},
+				{0.52497918747894, 0.47502081252106},
+				{0.598687660112452, 0.401312339887548},
+			},
+		},
+		{
+			name:  "lightgbm random forest multiclass",
+			model: lgbModel("multiclass num_class:3", `"num_class":3,"average_output":true,`, 3, lgbMulti...),
+			want: [][]float64{
+				{0.372993325559969, 0.297841219948631, 0.3291654544914},
+				{0.316272113979359, 0.316272113979359, 0.367455772041282},
+				{0.275000776987891, 0.344389719992349, 0.38060950301976},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseTreeModel([]byte(tt.model))
+			if err != nil {
+				t.Fatalf("ParseTreeModel: %v", err)
+			}
+			for i, req := range treeInputs {
+				got, err := m.Predict(req)
+				if err != nil {
+					t.Fatalf("Predict(%v): %v", req, err)
+				}
+				best := 0
+				for c, class := range m.classes {
+					if p := got.Meta.Probabilities[class]; math.Abs(p-tt.want[i][c]) > 1e-9 {
+						t.Errorf("input %d: P(%s) = %.12f, want %.12f", i, class, p, tt.want[i][c])
+					}
+					if tt.want[i][c] > tt.want[i][best] {
+						best = c
+					}
+				}
+				if got.RiskLevel != m.classes[best] {
+					t.Errorf("input %d: risk level %q, want %q", i, got.RiskLevel, m.classes[best])
+				}
+			}
+		})
+	}
+}
+
+func TestParseTreeModelRejects(t *testing.T) {
+	tests := map[string]string{
+		"lightgbm one-vs-all":      lgbModel("multiclassova num_class:3", `"num_class":3,`, 3, lgbMulti...),
+		"incomplete round":         xgbModel("multi:softprob", "", 3, xgbMulti[:5]...),
+		"incomplete parallel tree": xgbModel("random_forest", `"num_parallel_tree":4,`, 3, xgbMulti...),
+		"binary with three classes": xgbModel("binary:logistic", "", 3,
+			xgbStump("PM2_5", 25, -0.4, 0.6)),
+		"unknown split feature": xgbModel("binary:logistic", "", 2, xgbStump("SO2", 25, -0.4, 0.6)),
+	}
+	for name, model := range tests {
+		if _, err := ParseTreeModel([]byte(model)); err == nil {
+			t.Errorf("%s: model was accepted", name)
+		}
+	}
+}
+
+func TestTreeModelMissingFeature(t *testing.T) {
+	m, err := ParseTreeModel([]byte(xgbModel("multi:softprob", "", 3, xgbMulti...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Predict(PredictionRequest{"PM2_5": 10}); err == nil {
+		t.Error("prediction without NO2 succeeded")
+	}
+}
@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"slices"
+	"time"
+
+	"nasa-app/internal/airquality"
+	"nasa-app/internal/mlclient"
+)
+
+// Alert kinds.
+const (
+	KindAlert    = "alert"     // threshold crossed or escalated
+	KindAllClear = "all_clear" // conditions recovered after an alert
+	KindDeferred = "deferred"  // alerts held back during quiet hours, sent together
+)
+
+// Alert is what a notifier needs to tell a user about one reading.
+type Alert struct {
+	Kind         string
+	At           time.Time // time of the reading
+	Subscription Notification
+	Metrics      airquality.Metrics
+	RiskLevel    string                      // ML risk level, "unknown" without a prediction
+	Prediction   mlclient.PredictionResponse // the scheduler's ML prediction behind RiskLevel
+	Evaluation   Evaluation
+	Drivers      []string // main pollutants, filled in by the notifier
+	Episode      *Episode // set for all-clear alerts
+	Batch        []Alert  // set for deferred alerts, oldest first
+}
+
+// Hazardous reports whether the alert is severe enough to break through quiet
+// hours and to go out by SMS. Alerts held back during quiet hours keep their
+// severity: the deferred batch is hazardous if any alert in it is.
+func (a Alert) Hazardous() bool {
+	if a.Kind == KindDeferred {
+		return slices.ContainsFunc(a.Batch, Alert.Hazardous)
+	}
+	if a.Kind != KindAlert {
+		return false
+	}
+	switch a.Evaluation.AQI.Category {
+	case "hazardous", "extremely_poor":
+		return true
+	}
+	return a.RiskLevel == "hazardous"
+}
@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"nasa-app/internal/airquality"
+)
+
+// AlertState is the persisted alerting state of one subscription. An active
+// state means an alert went out and the episode has not ended yet.
+type AlertState struct {
+	NotificationID uint `gorm:"primaryKey"`
+	UpdatedAt      time.Time
+	Rule           string // threshold the state belongs to; a changed rule resets the state
+	Active         bool
+	Level          int // Evaluation.Level of the last alert
+	LastValue      float64
+	LastAlertAt    *time.Time
+
+	// Episode bilgisi: ilk uyarıdan kapanışa kadar görülen en yüksek değerler
+	EpisodeStart *time.Time
+	ClearSince   *time.Time // first reading of the current run below the re-arm level
+	PeakValue    float64
+	PeakAQI      int
+	Peaks        map[string]float64 `gorm:"type:jsonb;serializer:json"` // pollutant feature -> µg/m³
+}
+
+func (AlertState) TableName() string { return "notification_alert_states" }
+
+// Episode is a closed period during which a subscription was alerting.
+type Episode struct {
+	Start     time.Time          `json:"start"`
+	End       time.Time          `json:"end"` // first reading below the re-arm level, not the all-clear itself
+	PeakValue float64            `json:"peak_value"`
+	PeakAQI   int                `json:"peak_aqi"`
+	Peaks     map[string]float64 `json:"peaks"`
+}
+
+// Duration is how long the episode lasted.
+func (e Episode) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Action is what the scheduler should send for a reading.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionAlert
+	ActionAllClear
+)
+
+// AlertPolicy decides whether a reading is worth an alert given the previous state.
+type AlertPolicy struct {
+	// Cooldown is the minimum time between two alerts of a subscription.
+	Cooldown time.Duration
+	// Hysteresis is the fraction below the threshold the value must fall
+	// before an active alert re-arms (AQI and pollutant thresholds only;
+	// risk categories re-arm as soon as they drop below the minimum).
+	Hysteresis float64
+	// AllClearAfter is how long readings must stay below the re-arm level
+	// before the episode ends (and an all-clear goes out if requested).
+	AllClearAfter time.Duration
+}
+
+// ruleKey identifies the threshold settings of n.
+func ruleKey(n Notification) string {
+	r := n.Rule()
+	return fmt.Sprintf("%s:%s:%s:%s:%d", r.ThresholdKind, r.AQIStandard, r.Pollutant, r.MinRisk, r.Threshold)
+}
+
+// Decide returns what to send and the state to store afterwards. Alerts go
+// out when the threshold is first crossed and again only when the reading
+// moves up a level, never within the cooldown. The episode ends once the
+// value has stayed below the threshold by the hysteresis margin for
+// AllClearAfter; subscriptions that opted in get an all-clear then. An
+// unknown risk level carries no information and leaves the state as it is.
+func (p AlertPolicy) Decide(n Notification, state AlertState, ev Evaluation, metrics airquality.Metrics, now time.Time) (Action, AlertState, *Episode) {
+	// Risk bilinmiyor (ML kapalı, devre açık, fallback): yeniden kurma yok, all-clear yok
+	if ev.Kind == ThresholdRisk && ev.Value < 0 {
+		return ActionNone, state, nil
+	}
+
+	next := state
+	next.NotificationID = n.ID
+	if key := ruleKey(n); state.Rule != key {
+		next = AlertState{NotificationID: n.ID, Rule: key}
+	}
+	next.LastValue = ev.Value
+
+	if !ev.Exceeded {
+		if !next.Active {
+			return ActionNone, next, nil
+		}
+		if !p.rearms(ev) {
+			next.ClearSince = nil
+			next.track(ev, metrics)
+			return ActionNone, next, nil
+		}
+		if next.ClearSince == nil {
+			next.ClearSince = &now
+		}
+		if now.Sub(*next.ClearSince) < p.AllClearAfter {
+			return ActionNone, next, nil
+		}
+
+		episode := &Episode{End: *next.ClearSince, PeakValue: next.PeakValue, PeakAQI: next.PeakAQI, Peaks: next.Peaks}
+		if next.EpisodeStart != nil {
+			episode.Start = *next.EpisodeStart
+		}
+		next = AlertState{NotificationID: n.ID, Rule: next.Rule, LastValue: ev.Value, LastAlertAt: next.LastAlertAt}
+		if !n.AllClear {
+			return ActionNone, next, nil
+		}
+		return ActionAllClear, next, episode
+	}
+
+	next.ClearSince = nil
+	if next.Active {
+		next.track(ev, metrics)
+	}
+	if next.Active && ev.Level <= next.Level {
+		return ActionNone, next, nil
+	}
+	if next.LastAlertAt != nil && now.Sub(*next.LastAlertAt) < p.Cooldown {
+		return ActionNone, next, nil
+	}
+
+	if !next.Active {
+		next.EpisodeStart = &now
+		next.track(ev, metrics)
+	}
+	next.Active = true
+	next.Level = ev.Level
+	next.LastAlertAt = &now
+	return ActionAlert, next, nil
+}
+
+func (p AlertPolicy) rearms(ev Evaluation) bool {
+	if ev.Kind == ThresholdRisk {
+		return true
+	}
+	return ev.Value < ev.Limit*(1-p.Hysteresis)
+}
+
+// track records the peaks of an active episode.
+func (s *AlertState) track(ev Evaluation, metrics airquality.Metrics) {
+	if ev.Value > s.PeakValue {
+		s.PeakValue = ev.Value
+	}
+	if ev.AQI.Value > s.PeakAQI {
+		s.PeakAQI = ev.AQI.Value
+	}
+	if s.Peaks == nil {
+		s.Peaks = map[string]float64{}
+	}
+	values := metrics.FeatureMap()
+	for _, p := range airquality.Pollutants {
+		if v := values[p.Feature]; v > s.Peaks[p.Feature] {
+			s.Peaks[p.Feature] = v
+		}
+	}
+}
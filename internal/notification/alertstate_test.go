@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"nasa-app/internal/airquality"
+)
+
+func TestDecide(t *testing.T) {
+	policy := AlertPolicy{Cooldown: time.Hour, Hysteresis: 0.2, AllClearAfter: 30 * time.Minute}
+	// PM2.5 eşiği 50; yeniden kurma seviyesi 40
+	n := Notification{ID: 1, ThresholdKind: ThresholdPollutant, Pollutant: "pm2_5", Threshold: 50, AllClear: true}
+	start := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+
+	type step struct {
+		minute int
+		pm25   float64
+		want   Action
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"first breach", []step{
+			{0, 30, ActionNone},
+			{30, 60, ActionAlert},
+		}},
+		{"repeat inside the cooldown", []step{
+			{0, 60, ActionAlert},
+			{30, 60, ActionNone},
+			{45, 120, ActionNone}, // bir seviye yukarı, ama bekleme süresi dolmadı
+		}},
+		{"repeat after the cooldown", []step{
+			{0, 60, ActionAlert},
+			{90, 70, ActionNone}, // aynı seviye
+			{120, 120, ActionAlert},
+		}},
+		{"dip inside the hysteresis band", []step{
+			{0, 60, ActionAlert},
+			{30, 45, ActionNone},
+			{90, 45, ActionNone},  // eşiğin altında ama 40'ın üstünde: all-clear yok
+			{150, 60, ActionNone}, // aynı episode, tekrar uyarı yok
+		}},
+		{"clearing sends one all-clear", []step{
+			{0, 60, ActionAlert},
+			{10, 30, ActionNone},
+			{40, 30, ActionAllClear},
+			{50, 30, ActionNone},
+			{120, 30, ActionNone},
+		}},
+	}
+
+	for _, tt := range tests {
+		var state AlertState
+		for i, s := range tt.steps {
+			metrics := airquality.Metrics{PM25: s.pm25}
+			now := start.Add(time.Duration(s.minute) * time.Minute)
+			action, next, episode := policy.Decide(n, state, n.Evaluate(metrics, ""), metrics, now)
+			if action != s.want {
+				t.Errorf("%s: step %d (minute %d, PM2.5 %g): action %d, want %d", tt.name, i, s.minute, s.pm25, action, s.want)
+			}
+			if (episode != nil) != (action == ActionAllClear) {
+				t.Errorf("%s: step %d: episode %+v with action %d", tt.name, i, episode, action)
+			}
+			state = next
+		}
+	}
+}
+
+func TestDecideAllClearEpisode(t *testing.T) {
+	policy := AlertPolicy{Cooldown: time.Hour, Hysteresis: 0.2, AllClearAfter: 30 * time.Minute}
+	n := Notification{ID: 1, ThresholdKind: ThresholdPollutant, Pollutant: "pm2_5", Threshold: 50, AllClear: true}
+	start := time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC)
+
+	var state AlertState
+	var episode *Episode
+	for _, s := range []struct {
+		minute int
+		pm25   float64
+	}{{0, 60}, {30, 95}, {60, 70}, {90, 20}, {150, 20}} {
+		metrics := airquality.Metrics{PM25: s.pm25}
+		var action Action
+		action, state, episode = policy.Decide(n, state, n.Evaluate(metrics, ""), metrics, start.Add(time.Duration(s.minute)*time.Minute))
+		if action == ActionAllClear {
+			break
+		}
+	}
+	if episode == nil {
+		t.Fatal("no all-clear")
+	}
+	if !episode.Start.Equal(start) || episode.Duration() != 90*time.Minute {
+		t.Errorf("episode %s from %s, want 1h30m from %s", episode.Duration(), episode.Start, start)
+	}
+	if episode.PeakValue != 95 || episode.Peaks["pm2_5"] != 95 {
+		t.Errorf("episode peak %g (pm2_5 %g), want 95", episode.PeakValue, episode.Peaks["pm2_5"])
+	}
+	if state.Active {
+		t.Error("state still active after the all-clear")
+	}
+
+	// Abonelik all-clear istemiyorsa episode sessizce kapanır
+	n.AllClear = false
+	state = AlertState{}
+	for _, s := range []struct {
+		minute int
+		pm25   float64
+	}{{0, 60}, {10, 30}, {40, 30}} {
+		metrics := airquality.Metrics{PM25: s.pm25}
+		action, next, _ := policy.Decide(n, state, n.Evaluate(metrics, ""), metrics, start.Add(time.Duration(s.minute)*time.Minute))
+		if s.minute == 40 && (action != ActionNone || next.Active) {
+			t.Errorf("opted out: action %d, active %v; want a silent reset", action, next.Active)
+		}
+		state = next
+	}
+}
@@ -0,0 +1,278 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// riskColours are the RGB colours of the advice levels (green, yellow, orange, red).
+var riskColours = map[string]int{
+	"good":      0x2e7d32,
+	"moderate":  0xf9a825,
+	"poor":      0xef6c00,
+	"hazardous": 0xc62828,
+}
+
+// riskEmoji stands in for the colour where the platform has none (Telegram).
+var riskEmoji = map[string]string{
+	"good":      "🟢",
+	"moderate":  "🟡",
+	"poor":      "🟠",
+	"hazardous": "🔴",
+}
+
+// chatLabels are the few words chat messages need besides the short templates.
+var chatLabels = map[string]map[string]string{
+	"tr": {"temperature": "Sıcaklık", "humidity": "Nem", "drivers": "Başlıca nedenler"},
+	"en": {"temperature": "Temperature", "humidity": "Humidity", "drivers": "Main causes"},
+}
+
+// telegramChatID is a numeric chat id, the form the link handshake stores.
+var telegramChatID = regexp.MustCompile(`^-?[0-9]{1,20}$`)
+
+// ValidateChat checks the targets of the chat channels the subscription uses.
+func (n Notification) ValidateChat() error {
+	if n.HasChannel(ChannelSlack) && !chatWebhookURL(n.SlackWebhookURL, "hooks.slack.com", "/services/") {
+		return errors.New("slack_webhook_url must be a Slack incoming webhook (https://hooks.slack.com/services/...)")
+	}
+	if n.HasChannel(ChannelDiscord) && !chatWebhookURL(n.DiscordWebhookURL, "discord.com", "/api/webhooks/") &&
+		!chatWebhookURL(n.DiscordWebhookURL, "discordapp.com", "/api/webhooks/") {
+		return errors.New("discord_webhook_url must be a Discord webhook (https://discord.com/api/webhooks/...)")
+	}
+	if n.HasChannel(ChannelTelegram) && !telegramChatID.MatchString(n.TelegramChatID) {
+		return errors.New("telegram_chat_id must be the numeric id of a linked chat")
+	}
+	return nil
+}
+
+func chatWebhookURL(raw, host, pathPrefix string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && strings.EqualFold(u.Host, host) &&
+		strings.HasPrefix(u.Path, pathPrefix) && len(raw) <= 512
+}
+
+// chatMessage is what all chat formats are built from.
+type chatMessage struct {
+	Title        string
+	Body         string
+	Severity     string // good, moderate, poor or hazardous
+	AQILabel     string // "AQI (US EPA)"
+	AQI          int
+	Metrics      [][2]string // label, value with unit
+	Drivers      string
+	DriversLabel string
+	At           time.Time
+}
+
+func newChatMessage(alert Alert) (chatMessage, error) {
+	n := alert.Subscription
+	locale := n.EmailLocale()
+	data := alertData(alert)
+	title, body, err := renderShort(locale, alert.Kind, data)
+	if err != nil {
+		return chatMessage{}, err
+	}
+
+	msg := chatMessage{
+		Title:        title,
+		Body:         body,
+		Severity:     data.Severity,
+		AQILabel:     "AQI (" + standardLabel(alert.Evaluation.AQI.Standard) + ")",
+		AQI:          alert.Evaluation.AQI.Value,
+		Drivers:      strings.Join(alert.Drivers, ", "),
+		DriversLabel: chatLabels[locale]["drivers"],
+		At:           alert.At,
+	}
+	if alert.Kind == KindAllClear {
+		msg.Severity = "good"
+	}
+	for _, row := range data.Metrics {
+		label := row.Label
+		if label == "" {
+			label = chatLabels[locale][row.Key]
+		}
+		msg.Metrics = append(msg.Metrics, [2]string{label, row.Value + " " + row.Unit})
+	}
+	return msg, nil
+}
+
+// ChatSender posts alerts to Slack and Discord incoming webhooks and to
+// Telegram chats through one bot.
+type ChatSender struct {
+	client        *http.Client
+	telegramAPI   string
+	telegramToken string
+}
+
+// NewChatSender creates a sender. If client is nil, a client with a 10 second
+// timeout is used. Telegram needs a bot token; telegramAPI defaults to the
+// public Bot API.
+func NewChatSender(client *http.Client, telegramAPI, telegramToken string) *ChatSender {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if telegramAPI == "" {
+		telegramAPI = "https://api.telegram.org"
+	}
+	return &ChatSender{client: client, telegramAPI: strings.TrimRight(telegramAPI, "/"), telegramToken: telegramToken}
+}
+
+// SendSlack posts a Block Kit message; the attachment bar carries the risk colour.
+func (s *ChatSender) SendSlack(alert Alert) error {
+	msg, err := newChatMessage(alert)
+	if err != nil {
+		return err
+	}
+
+	fields := []map[string]any{
+		{"type": "mrkdwn", "text": "*" + escapeSlack(msg.AQILabel) + "*\n" + fmt.Sprint(msg.AQI)},
+	}
+	for _, m := range msg.Metrics {
+		if len(fields) == 10 { // Slack section limit
+			break
+		}
+		fields = append(fields, map[string]any{"type": "mrkdwn", "text": "*" + escapeSlack(m[0]) + "*\n" + escapeSlack(m[1])})
+	}
+	blocks := []map[string]any{
+		{"type": "header", "text": map[string]any{"type": "plain_text", "text": msg.Title}},
+		{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": escapeSlack(msg.Body)}},
+		{"type": "section", "fields": fields},
+	}
+	if msg.Drivers != "" {
+		blocks = append(blocks, map[string]any{
+			"type":     "context",
+			"elements": []map[string]any{{"type": "mrkdwn", "text": "*" + escapeSlack(msg.DriversLabel) + ":* " + escapeSlack(msg.Drivers)}},
+		})
+	}
+
+	payload := map[string]any{
+		"text": msg.Title + "\n" + msg.Body, // notification fallback
+		"attachments": []map[string]any{{
+			"color":  fmt.Sprintf("#%06x", riskColours[msg.Severity]),
+			"blocks": blocks,
+		}},
+	}
+	return s.postJSON("slack", alert.Subscription.SlackWebhookURL, payload)
+}
+
+// SendDiscord posts an embed coloured by risk.
+func (s *ChatSender) SendDiscord(alert Alert) error {
+	msg, err := newChatMessage(alert)
+	if err != nil {
+		return err
+	}
+
+	fields := []map[string]any{{"name": msg.AQILabel, "value": fmt.Sprint(msg.AQI), "inline": true}}
+	for _, m := range msg.Metrics {
+		fields = append(fields, map[string]any{"name": m[0], "value": m[1], "inline": true})
+	}
+	if msg.Drivers != "" {
+		fields = append(fields, map[string]any{"name": msg.DriversLabel, "value": msg.Drivers})
+	}
+	embed := map[string]any{
+		"title":       msg.Title,
+		"description": msg.Body,
+		"color":       riskColours[msg.Severity],
+		"fields":      fields,
+		"footer":      map[string]any{"text": "Clean Breathing"},
+	}
+	if !msg.At.IsZero() {
+		embed["timestamp"] = msg.At.UTC().Format(time.RFC3339)
+	}
+	payload := map[string]any{"username": "Clean Breathing", "embeds": []map[string]any{embed}}
+	return s.postJSON("discord", alert.Subscription.DiscordWebhookURL, payload)
+}
+
+// SendTelegram sends a MarkdownV2 message through the bot.
+func (s *ChatSender) SendTelegram(alert Alert) error {
+	if s.telegramToken == "" {
+		return errors.New("telegram bot token is not configured")
+	}
+	msg, err := newChatMessage(alert)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s *%s*\n\n%s\n\n", riskEmoji[msg.Severity], escapeTelegram(msg.Title), escapeTelegram(msg.Body))
+	fmt.Fprintf(&b, "*%s:* %d\n", escapeTelegram(msg.AQILabel), msg.AQI)
+	for _, m := range msg.Metrics {
+		fmt.Fprintf(&b, "• %s: %s\n", escapeTelegram(m[0]), escapeTelegram(m[1]))
+	}
+	if msg.Drivers != "" {
+		fmt.Fprintf(&b, "\n_%s:_ %s", escapeTelegram(msg.DriversLabel), escapeTelegram(msg.Drivers))
+	}
+
+	payload := map[string]any{
+		"chat_id":    alert.Subscription.TelegramChatID,
+		"text":       b.String(),
+		"parse_mode": "MarkdownV2",
+	}
+	return s.postJSON("telegram", s.telegramAPI+"/bot"+s.telegramToken+"/sendMessage", payload)
+}
+
+// SendTelegramText sends a plain text message through the bot, e.g. the
+// reply to a link handshake.
+func (s *ChatSender) SendTelegramText(chatID, text string) error {
+	if s.telegramToken == "" {
+		return errors.New("telegram bot token is not configured")
+	}
+	payload := map[string]any{"chat_id": chatID, "text": text}
+	return s.postJSON("telegram", s.telegramAPI+"/bot"+s.telegramToken+"/sendMessage", payload)
+}
+
+// SetTelegramWebhook tells Telegram to deliver the bot's messages to target,
+// sending secret in the X-Telegram-Bot-Api-Secret-Token header.
+func (s *ChatSender) SetTelegramWebhook(target, secret string) error {
+	if s.telegramToken == "" {
+		return errors.New("telegram bot token is not configured")
+	}
+	payload := map[string]any{"url": target, "secret_token": secret, "allowed_updates": []string{"message"}}
+	return s.postJSON("telegram", s.telegramAPI+"/bot"+s.telegramToken+"/setWebhook", payload)
+}
+
+// escapeSlack escapes the control characters of Slack mrkdwn.
+var escapeSlack = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+
+// telegramEscaper escapes the characters MarkdownV2 reserves.
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func escapeTelegram(s string) string {
+	return telegramEscaper.Replace(s)
+}
+
+// postJSON posts payload to a chat API; name is used in error messages.
+// The URL is left out of errors since webhook URLs and bot tokens are secrets.
+func (s *ChatSender) postJSON(name, target string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s message: %w", name, err)
+	}
+	resp, err := s.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return fmt.Errorf("%s request: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s request failed: status %d, response: %s", name, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
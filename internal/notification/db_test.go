@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRepo returns a repository on a private in-memory SQLite database.
+// Row locks (FOR UPDATE SKIP LOCKED) are ignored by SQLite; everything else
+// runs the same queries as on Postgres.
+func newTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(&Notification{}, &AlertState{}, &DeferredAlert{}, &OutboxMessage{}, &TelegramChat{}); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	return NewRepository(db)
+}
@@ -0,0 +1,216 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"nasa-app/internal/airquality"
+)
+
+// Subscription types. Alert subscriptions are checked by StartScheduler,
+// digest subscriptions get one summary per day or week from StartDigestScheduler.
+const (
+	TypeAlert  = "alert"
+	TypeDigest = "digest"
+)
+
+// Digest frequencies.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const (
+	defaultDigestTime = "07:00"
+	// digestForecastHours is how far ahead the digest timeline looks.
+	digestForecastHours = 24
+	// outdoorWindowHours is the length of the recommended outdoor window.
+	outdoorWindowHours = 2
+	// outdoor windows are only suggested between these local hours
+	outdoorFromHour, outdoorToHour = 6, 22
+)
+
+// ValidateDigest checks the digest settings of a digest subscription.
+func (n Notification) ValidateDigest() error {
+	switch n.Type {
+	case TypeAlert, "":
+		return nil
+	case TypeDigest:
+	default:
+		return fmt.Errorf("type must be %s or %s", TypeAlert, TypeDigest)
+	}
+	if !slices.Contains([]string{DigestDaily, DigestWeekly}, n.DigestFrequency) {
+		return fmt.Errorf("digest_frequency must be %s or %s", DigestDaily, DigestWeekly)
+	}
+	if _, err := time.Parse(quietClock, n.DigestTime); err != nil {
+		return fmt.Errorf("digest_time must be HH:MM, got %q", n.DigestTime)
+	}
+	if n.DigestWeekday < 0 || n.DigestWeekday > 6 {
+		return fmt.Errorf("digest_weekday must be between 0 (Sunday) and 6")
+	}
+	return nil
+}
+
+// DigestDue reports whether the digest should go out at now: the local
+// send time has passed today (on the chosen weekday for weekly digests) and
+// nothing was sent yet today.
+func (n Notification) DigestDue(now time.Time) bool {
+	if n.Type != TypeDigest {
+		return false
+	}
+	at, err := time.Parse(quietClock, n.DigestTime)
+	if err != nil {
+		at, _ = time.Parse(quietClock, defaultDigestTime)
+	}
+
+	local := now.In(n.Location())
+	if n.DigestFrequency == DigestWeekly && int(local.Weekday()) != n.DigestWeekday {
+		return false
+	}
+	sendAt := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, local.Location())
+	if local.Before(sendAt) {
+		return false
+	}
+	return n.LastDigestAt == nil || n.LastDigestAt.Before(sendAt)
+}
+
+// OutdoorWindow is the best time to be outside in the forecast.
+type OutdoorWindow struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	RiskLevel string    `json:"risk_level"` // worst risk in the window
+	AQI       int       `json:"aqi"`        // worst AQI in the window
+}
+
+// Digest is the summary sent to a digest subscription.
+type Digest struct {
+	Subscription Notification
+	Frequency    string
+	PeriodStart  time.Time // local start of the summarised days
+	PeriodEnd    time.Time // local end (exclusive)
+	Stats        []airquality.PollutantStats
+	Timeline     []airquality.TimelinePoint
+	BestWindow   *OutdoorWindow
+}
+
+// digestPeriod returns the local days a digest sent at now summarises:
+// yesterday for daily digests, the last seven days for weekly ones.
+func digestPeriod(n Notification, now time.Time) (time.Time, time.Time) {
+	local := now.In(n.Location())
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	days := 1
+	if n.DigestFrequency == DigestWeekly {
+		days = 7
+	}
+	return end.AddDate(0, 0, -days), end
+}
+
+// bestOutdoorWindow picks the outdoorWindowHours-long run of forecast hours
+// with the lowest average score during local daytime. Hours are scored by
+// risk category first and AQI second, so the window works without ML too.
+func bestOutdoorWindow(n Notification, points []airquality.TimelinePoint) *OutdoorWindow {
+	r := n.Rule()
+	loc := n.Location()
+	score := func(p airquality.TimelinePoint) (float64, int) {
+		aqi, _ := airquality.AQI(r.AQIStandard, p.Metrics)
+		s := float64(aqi.Value)
+		if rank := riskRank(p.RiskLevel); rank >= 0 {
+			s += float64(rank) * 1000
+		}
+		return s, aqi.Value
+	}
+
+	var best *OutdoorWindow
+	bestScore := 0.0
+	for i := 0; i+outdoorWindowHours <= len(points); i++ {
+		window := points[i : i+outdoorWindowHours]
+		start := window[0].Time.In(loc)
+		end := window[len(window)-1].Time.Add(time.Hour).In(loc)
+		if start.Hour() < outdoorFromHour || end.Hour() > outdoorToHour || end.Day() != start.Day() {
+			continue
+		}
+		// Pencere boyunca saatler ardışık olmalı (eksik saatler atlanmış olabilir)
+		if window[len(window)-1].Time.Sub(window[0].Time) != time.Duration(outdoorWindowHours-1)*time.Hour {
+			continue
+		}
+
+		total, worstAQI, worstRisk := 0.0, 0, -1
+		for _, p := range window {
+			s, aqi := score(p)
+			total += s
+			worstAQI = max(worstAQI, aqi)
+			worstRisk = max(worstRisk, riskRank(p.RiskLevel))
+		}
+		avg := total / float64(len(window))
+		if best == nil || avg < bestScore {
+			risk := "unknown"
+			if worstRisk >= 0 {
+				risk = RiskLevels[worstRisk]
+			}
+			best = &OutdoorWindow{Start: window[0].Time, End: window[len(window)-1].Time.Add(time.Hour), RiskLevel: risk, AQI: worstAQI}
+			bestScore = avg
+		}
+	}
+	return best
+}
+
+// StartDigestScheduler checks digest subscriptions every interval and sends
+// the ones that are due. It runs separately from the threshold loop.
+func StartDigestScheduler(
+	repo *Repository,
+	interval time.Duration,
+	statsFunc func(latitude, longitude float64, from, to time.Time) ([]airquality.PollutantStats, error),
+	timelineFunc func(latitude, longitude float64, hours int) (airquality.Timeline, error),
+	sendFunc func(Digest) error,
+) {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	go func() {
+		for {
+			subs, err := repo.ListByType(TypeDigest)
+			if err != nil {
+				log.Println("Digest scheduler DB error:", err)
+				time.Sleep(time.Minute)
+				continue
+			}
+
+			now := time.Now()
+			for _, n := range subs {
+				if !n.DigestDue(now) {
+					continue
+				}
+
+				d := Digest{Subscription: n, Frequency: n.DigestFrequency}
+				d.PeriodStart, d.PeriodEnd = digestPeriod(n, now)
+
+				// Özet veya tahmin alınamazsa eldeki kısımla gönder
+				if d.Stats, err = statsFunc(n.Latitude, n.Longitude, d.PeriodStart, d.PeriodEnd.Add(-time.Second)); err != nil {
+					log.Printf("Digest stats error for subscription %d: %v", n.ID, err)
+				}
+				if timeline, err := timelineFunc(n.Latitude, n.Longitude, digestForecastHours); err != nil {
+					log.Printf("Digest forecast error for subscription %d: %v", n.ID, err)
+				} else {
+					d.Timeline = timeline.Points
+					d.BestWindow = bestOutdoorWindow(n, timeline.Points)
+				}
+				if len(d.Stats) == 0 && len(d.Timeline) == 0 {
+					continue
+				}
+
+				if err := sendFunc(d); err != nil {
+					log.Printf("Digest send error for subscription %d: %v", n.ID, err)
+					continue
+				}
+				if err := repo.MarkDigestSent(n.ID, now); err != nil {
+					log.Printf("Digest state save error for subscription %d: %v", n.ID, err)
+				}
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
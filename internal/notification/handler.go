@@ -1,33 +1,397 @@
 package notification
 
 import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gorilla/sessions"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
-	Repo  *Repository
-	Store *sessions.CookieStore
+	Repo   *Repository
+	Store  *sessions.CookieStore
+	Tokens *TokenSigner
+
+	// OwnEmail returns the sign-in address of a user; that address is trusted
+	// without verification. SendVerification mails the confirmation link.
+	OwnEmail         func(userID uint) (string, error)
+	SendVerification func(Notification) error
+
+	// PushPublicKey is the VAPID applicationServerKey; empty when web push is off.
+	PushPublicKey string
+
+	// OwnPhone returns the user's profile phone number; the sms channel needs
+	// one. Nil when sms is not configured, and then the channel is refused.
+	OwnPhone func(userID uint) (string, error)
+
+	// TelegramEnabled is set when the bot and its webhook are configured;
+	// otherwise the telegram channel is refused. TelegramBot is the bot's
+	// username for t.me links (may be empty), TelegramSecret authenticates
+	// the webhook and TelegramReply answers in a chat.
+	TelegramEnabled bool
+	TelegramBot     string
+	TelegramSecret  string
+	TelegramReply   func(chatID, text string) error
 }
 
 type subscribeRequest struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Threshold int     `json:"threshold"`
-	Email     string  `json:"email"`
+	Name              string   `json:"name"`
+	Latitude          float64  `json:"latitude"`
+	Longitude         float64  `json:"longitude"`
+	Threshold         int      `json:"threshold"`
+	ThresholdKind     string   `json:"threshold_kind"`
+	AQIStandard       string   `json:"aqi_standard"`
+	Pollutant         string   `json:"pollutant"`
+	MinRisk           string   `json:"min_risk"`
+	AllClear          bool     `json:"all_clear"`
+	Timezone          string   `json:"timezone"`
+	QuietStart        string   `json:"quiet_start"`
+	QuietEnd          string   `json:"quiet_end"`
+	QuietBreakthrough bool     `json:"quiet_breakthrough"`
+	Type              string   `json:"type"`
+	DigestFrequency   string   `json:"digest_frequency"`
+	DigestTime        string   `json:"digest_time"`
+	DigestWeekday     int      `json:"digest_weekday"`
+	Locale            string   `json:"locale"`
+	Email             string   `json:"email"`
+	Channels          []string `json:"channels"`
+	WebhookURL        string   `json:"webhook_url"`
+	SlackWebhookURL   string   `json:"slack_webhook_url"`
+	DiscordWebhookURL string   `json:"discord_webhook_url"`
+	TelegramChatID    string   `json:"telegram_chat_id"`
+}
+
+func NewHandler(repo *Repository, store *sessions.CookieStore, tokens *TokenSigner) *Handler {
+	return &Handler{Repo: repo, Store: store, Tokens: tokens}
+}
+
+// UseVerification enables double opt-in for subscription addresses.
+func (h *Handler) UseVerification(ownEmail func(userID uint) (string, error), send func(Notification) error) {
+	h.OwnEmail = ownEmail
+	h.SendVerification = send
+}
+
+// UsePush enables browser push registrations under the given VAPID public key.
+func (h *Handler) UsePush(publicKey string) {
+	h.PushPublicKey = publicKey
+}
+
+// UseTelegram accepts the telegram channel for chats linked through the bot.
+func (h *Handler) UseTelegram(bot, secret string, reply func(chatID, text string) error) {
+	h.TelegramEnabled = true
+	h.TelegramBot = strings.TrimPrefix(bot, "@")
+	h.TelegramSecret = secret
+	h.TelegramReply = reply
+}
+
+// UsePhone lets the sms channel check the user's profile phone number.
+func (h *Handler) UsePhone(ownPhone func(userID uint) (string, error)) {
+	h.OwnPhone = ownPhone
 }
 
-func NewHandler(repo *Repository, store *sessions.CookieStore) *Handler {
-	return &Handler{Repo: repo, Store: store}
+// validate normalises the request and returns a user-facing error message.
+func (req *subscribeRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = DefaultName
+	}
+	if len(req.Name) > 64 {
+		return "name must be at most 64 characters"
+	}
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return "latitude/longitude out of range"
+	}
+	if len(req.Channels) == 0 {
+		req.Channels = []string{ChannelEmail}
+	}
+	for i, ch := range req.Channels {
+		req.Channels[i] = strings.ToLower(strings.TrimSpace(ch))
+		if !slices.Contains(Channels, req.Channels[i]) {
+			return "unsupported channel: " + ch
+		}
+	}
+
+	req.ThresholdKind = strings.ToLower(strings.TrimSpace(req.ThresholdKind))
+	req.AQIStandard = strings.ToLower(strings.TrimSpace(req.AQIStandard))
+	req.Pollutant = strings.ToLower(strings.TrimSpace(req.Pollutant))
+	req.MinRisk = strings.ToLower(strings.TrimSpace(req.MinRisk))
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	req.QuietStart = strings.TrimSpace(req.QuietStart)
+	req.QuietEnd = strings.TrimSpace(req.QuietEnd)
+	req.WebhookURL = strings.TrimSpace(req.WebhookURL)
+	req.SlackWebhookURL = strings.TrimSpace(req.SlackWebhookURL)
+	req.DiscordWebhookURL = strings.TrimSpace(req.DiscordWebhookURL)
+	req.TelegramChatID = strings.TrimSpace(req.TelegramChatID)
+	req.Locale = strings.ToLower(strings.TrimSpace(req.Locale))
+	if req.Locale == "" {
+		req.Locale = DefaultLocale
+	}
+	if !slices.Contains(Locales, req.Locale) {
+		return "locale must be one of " + strings.Join(Locales, ", ")
+	}
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	if req.Type == "" {
+		req.Type = TypeAlert
+	}
+	req.DigestFrequency = strings.ToLower(strings.TrimSpace(req.DigestFrequency))
+	req.DigestTime = strings.TrimSpace(req.DigestTime)
+	if req.Type == TypeDigest {
+		if req.DigestFrequency == "" {
+			req.DigestFrequency = DigestDaily
+		}
+		if req.DigestTime == "" {
+			req.DigestTime = defaultDigestTime
+		}
+	}
+	var n Notification
+	req.apply(&n)
+	if err := n.ValidateRule(); err != nil {
+		return err.Error()
+	}
+	if err := n.ValidateQuietHours(); err != nil {
+		return err.Error()
+	}
+	if err := n.ValidateDigest(); err != nil {
+		return err.Error()
+	}
+	if err := n.ValidateWebhook(); err != nil {
+		return err.Error()
+	}
+	if err := n.ValidateChat(); err != nil {
+		return err.Error()
+	}
+	return ""
 }
 
+func (req *subscribeRequest) apply(n *Notification) {
+	n.Name = req.Name
+	n.Latitude = req.Latitude
+	n.Longitude = req.Longitude
+	if !strings.EqualFold(strings.TrimSpace(n.Email), strings.TrimSpace(req.Email)) {
+		// Yeni adres yeniden doğrulanmalı
+		n.EmailVerifiedAt = nil
+	}
+	n.Email = strings.TrimSpace(req.Email)
+	n.Locale = req.Locale
+	n.UnsubscribedAt = nil
+	n.Channels = req.Channels
+	n.WebhookURL = ""
+	if n.HasChannel(ChannelWebhook) {
+		n.WebhookURL = req.WebhookURL
+		if n.WebhookSecret == "" {
+			n.WebhookSecret = newWebhookSecret()
+		}
+	}
+	n.SlackWebhookURL, n.DiscordWebhookURL, n.TelegramChatID = "", "", ""
+	if n.HasChannel(ChannelSlack) {
+		n.SlackWebhookURL = req.SlackWebhookURL
+	}
+	if n.HasChannel(ChannelDiscord) {
+		n.DiscordWebhookURL = req.DiscordWebhookURL
+	}
+	if n.HasChannel(ChannelTelegram) {
+		n.TelegramChatID = req.TelegramChatID
+	}
+	n.AllClear = req.AllClear
+	n.Timezone = req.Timezone
+	n.QuietStart = req.QuietStart
+	n.QuietEnd = req.QuietEnd
+	n.QuietBreakthrough = req.QuietBreakthrough
+	n.Type = req.Type
+	n.DigestFrequency, n.DigestTime, n.DigestWeekday = "", "", 0
+	if n.Type == TypeDigest {
+		n.DigestFrequency = req.DigestFrequency
+		n.DigestTime = req.DigestTime
+		n.DigestWeekday = req.DigestWeekday
+	}
+
+	// Varsayılanlar kaydedilir ki API yanıtı kuralı açıkça göstersin
+	rule := Notification{
+		Threshold:     req.Threshold,
+		ThresholdKind: req.ThresholdKind,
+		AQIStandard:   req.AQIStandard,
+		Pollutant:     req.Pollutant,
+		MinRisk:       req.MinRisk,
+	}.Rule()
+	n.Threshold = rule.Threshold
+	n.ThresholdKind = rule.ThresholdKind
+	n.AQIStandard = rule.AQIStandard
+	n.Pollutant, n.MinRisk = "", ""
+	switch rule.ThresholdKind {
+	case ThresholdPollutant:
+		n.Pollutant = rule.Pollutant
+	case ThresholdRisk:
+		n.MinRisk = rule.MinRisk
+	}
+}
+
+// trustOwnEmail marks n verified when its address is the user's sign-in address.
+// Users only sign in with Google, so that address is already verified.
+func (h *Handler) trustOwnEmail(n *Notification) {
+	if n.Email == "" || n.EmailVerifiedAt != nil || h.OwnEmail == nil {
+		return
+	}
+	own, err := h.OwnEmail(n.UserID)
+	if err != nil {
+		log.Printf("own email lookup failed for user %d: %v", n.UserID, err)
+		return
+	}
+	if strings.EqualFold(own, n.Email) {
+		now := time.Now()
+		n.EmailVerifiedAt = &now
+	}
+}
+
+// checkChannels refuses channels the server can't deliver to, requires a
+// profile phone number for the sms channel and a linked chat for telegram.
+func (h *Handler) checkChannels(userID uint, req *subscribeRequest) string {
+	channels := req.Channels
+	if slices.Contains(channels, ChannelTelegram) {
+		if !h.TelegramEnabled {
+			return "telegram alerts are not available"
+		}
+		linked, err := h.Repo.TelegramLinked(userID, req.TelegramChatID)
+		if err != nil {
+			log.Printf("telegram chat lookup failed for user %d: %v", userID, err)
+			return "could not check your telegram chat"
+		}
+		if !linked {
+			return "link the telegram chat first: send the bot the /start code from POST /api/notifications/telegram/link"
+		}
+	}
+	if !slices.Contains(channels, ChannelSMS) {
+		return ""
+	}
+	if h.OwnPhone == nil {
+		return "sms alerts are not available"
+	}
+	phone, err := h.OwnPhone(userID)
+	if err != nil {
+		log.Printf("phone lookup failed for user %d: %v", userID, err)
+		return "could not check your phone number"
+	}
+	if phone == "" {
+		return "add a phone number to your profile before enabling sms"
+	}
+	return ""
+}
+
+// verificationCooldown is the minimum time between two verification mails of
+// one subscription, so the endpoints can't be used to flood an address.
+const verificationCooldown = 10 * time.Minute
+
+// needsVerification reports whether saving n should mail the verification
+// link on its own: once for each new or changed unconfirmed address. Further
+// mails are sent on request (ResendVerification).
+func (n Notification) needsVerification(now time.Time) bool {
+	return !strings.EqualFold(n.VerificationSentTo, n.Email) && n.canResendVerification(now)
+}
+
+// canResendVerification reports whether n's address is unconfirmed and the
+// cooldown since its last verification mail has passed.
+func (n Notification) canResendVerification(now time.Time) bool {
+	if n.Email == "" || n.EmailVerifiedAt != nil {
+		return false
+	}
+	return n.VerificationSentAt == nil || now.Sub(*n.VerificationSentAt) >= verificationCooldown
+}
+
+// requestVerification mails the confirmation link for a saved, unverified
+// address that hasn't been sent one yet; later mails go through
+// ResendVerification.
+func (h *Handler) requestVerification(n *Notification) {
+	now := time.Now()
+	if h.SendVerification == nil || !n.needsVerification(now) {
+		return
+	}
+	if err := sendVerification(h.Repo, n, now, h.SendVerification); err != nil {
+		log.Printf("verification email for subscription %d failed: %v", n.ID, err)
+	}
+}
+
+// sendVerification mails the link and records it on n.
+func sendVerification(repo *Repository, n *Notification, now time.Time, send func(Notification) error) error {
+	if err := send(*n); err != nil {
+		return err
+	}
+	n.VerificationSentTo, n.VerificationSentAt = n.Email, &now
+	return repo.MarkVerificationSent(n.ID, n.Email, now)
+}
+
+func currentUser(c *fiber.Ctx) (uint, bool) {
+	userID, ok := c.Locals("user_id").(uint)
+	return userID, ok && userID != 0
+}
+
+// Subscribe creates or replaces the subscription with the given name ("Default" if empty).
 func (h *Handler) Subscribe(c *fiber.Ctx) error {
 	// CORS ayarları Fiber'da globalde yapıldı
 
 	// Auth kontrolü: user_id Fiber context'ten alınır
-	uid := c.Locals("user_id")
-	userID, ok := uid.(uint)
-	if !ok || userID == 0 {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req subscribeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if msg := h.checkChannels(userID, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	n, err := h.Repo.FindByName(userID, req.Name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		n = &Notification{UserID: userID}
+		req.apply(n)
+		h.trustOwnEmail(n)
+		err = h.Repo.CreateNotification(n)
+	case err == nil:
+		req.apply(n)
+		h.trustOwnEmail(n)
+		err = h.Repo.UpdateNotification(n)
+	}
+	if err != nil {
+		if errors.Is(err, ErrLimitReached) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Subscription limit reached"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	h.requestVerification(n)
+
+	return c.JSON(fiber.Map{"message": "Subscription updated", "subscription": n})
+}
+
+// List returns the caller's subscriptions.
+func (h *Handler) List(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	notifications, err := h.Repo.ListByUser(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(fiber.Map{"subscriptions": notifications})
+}
+
+// Create adds a new named subscription; names are unique per user.
+func (h *Handler) Create(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
@@ -35,18 +399,396 @@ func (h *Handler) Subscribe(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if msg := h.checkChannels(userID, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
 
-	n := &Notification{
-		UserID:    userID,
-		Latitude:  req.Latitude,
-		Longitude: req.Longitude,
-		Threshold: req.Threshold,
-		Email:     req.Email,
+	if taken, err := h.nameTaken(userID, req.Name, 0); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	} else if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A subscription with this name already exists"})
 	}
 
-	if err := h.Repo.UpsertNotification(n); err != nil {
+	n := &Notification{UserID: userID}
+	req.apply(n)
+	h.trustOwnEmail(n)
+	if err := h.Repo.CreateNotification(n); err != nil {
+		if errors.Is(err, ErrLimitReached) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Subscription limit reached"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
+	h.requestVerification(n)
+
+	return c.Status(fiber.StatusCreated).JSON(n)
+}
+
+// Update replaces the fields of one of the caller's subscriptions.
+func (h *Handler) Update(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid subscription id"})
+	}
+
+	var req subscribeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if msg := req.validate(); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+	if msg := h.checkChannels(userID, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": msg})
+	}
+
+	n, err := h.Repo.FindByUser(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Subscription not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+
+	if taken, err := h.nameTaken(userID, req.Name, n.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	} else if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A subscription with this name already exists"})
+	}
+
+	req.apply(n)
+	h.trustOwnEmail(n)
+	if err := h.Repo.UpdateNotification(n); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	h.requestVerification(n)
+	return c.JSON(n)
+}
 
-	return c.JSON(fiber.Map{"message": "Subscription updated"})
+// Delete removes one of the caller's subscriptions.
+func (h *Handler) Delete(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid subscription id"})
+	}
+
+	err = h.Repo.DeleteNotification(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Subscription not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ResendVerification mails the confirmation link of a subscription again, for
+// when the first mail got lost or its link expired. Throttled by
+// verificationCooldown.
+func (h *Handler) ResendVerification(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid subscription id"})
+	}
+	if h.SendVerification == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Email verification is not available"})
+	}
+
+	n, err := h.Repo.FindByUser(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Subscription not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	if n.Email == "" || n.EmailVerifiedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Subscription has no unconfirmed address"})
+	}
+
+	now := time.Now()
+	if !n.canResendVerification(now) {
+		wait := verificationCooldown - now.Sub(*n.VerificationSentAt)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "A verification email was sent recently; try again later"})
+	}
+	if err := sendVerification(h.Repo, n, now, h.SendVerification); err != nil {
+		log.Printf("verification email for subscription %d failed: %v", n.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not send the verification email"})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Verification email sent"})
+}
+
+// pushSubscriptionRequest is the browser's PushSubscription.toJSON().
+type pushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// PushKey returns the VAPID public key the frontend subscribes with.
+func (h *Handler) PushKey(c *fiber.Ctx) error {
+	if h.PushPublicKey == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Web push is not configured"})
+	}
+	return c.JSON(fiber.Map{"public_key": h.PushPublicKey})
+}
+
+// SubscribePush registers the caller's browser for push alerts.
+func (h *Handler) SubscribePush(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if h.PushPublicKey == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Web push is not configured"})
+	}
+
+	var req pushSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	sub := &PushSubscription{
+		UserID:   userID,
+		Endpoint: strings.TrimSpace(req.Endpoint),
+		P256dh:   strings.TrimSpace(req.Keys.P256dh),
+		Auth:     strings.TrimSpace(req.Keys.Auth),
+	}
+	if err := sub.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.Repo.SavePushSubscription(sub); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(sub)
+}
+
+// UnsubscribePush removes one of the caller's browsers ({"endpoint": "..."}).
+func (h *Handler) UnsubscribePush(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req pushSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Endpoint) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	err := h.Repo.DeletePushSubscription(userID, strings.TrimSpace(req.Endpoint))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Push subscription not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// LinkTelegram issues a one-time code the caller sends to the bot as
+// "/start <code>" from the chat that should receive alerts.
+func (h *Handler) LinkTelegram(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if !h.TelegramEnabled {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Telegram alerts are not available"})
+	}
+
+	code, hash := newTelegramCode()
+	expiresAt := time.Now().Add(telegramCodeTTL)
+	if err := h.Repo.CreateTelegramCode(userID, hash, expiresAt); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	out := fiber.Map{"code": code, "command": "/start " + code, "expires_at": expiresAt}
+	if h.TelegramBot != "" {
+		out["url"] = "https://t.me/" + h.TelegramBot + "?start=" + code
+	}
+	return c.Status(fiber.StatusCreated).JSON(out)
+}
+
+// TelegramChats lists the caller's linked chats.
+func (h *Handler) TelegramChats(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	chats, err := h.Repo.TelegramChats(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(chats)
+}
+
+// UnlinkTelegram removes one of the caller's linked chats.
+func (h *Handler) UnlinkTelegram(c *fiber.Ctx) error {
+	userID, ok := currentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid chat id"})
+	}
+
+	err = h.Repo.DeleteTelegramChat(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Telegram chat not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TelegramWebhook receives the bot's updates. A "/start <code>" message
+// links the chat it came from to the user the code was issued to. Telegram
+// authenticates with the secret_token given to setWebhook.
+func (h *Handler) TelegramWebhook(c *fiber.Ctx) error {
+	if !h.TelegramEnabled {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	got := c.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.TelegramSecret)) != 1 {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	var update telegramUpdate
+	if err := c.BodyParser(&update); err != nil {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	if update.Message == nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	code, isStart := startCode(update.Message.Text)
+	if !isStart {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	chatID, title := update.chat()
+	reply := "Uyarıları bu sohbete almak için uygulamadaki bağlantı kodunu gönderin: /start <kod>\n" +
+		"To get alerts in this chat, send the link code from the app: /start <code>"
+	if code != "" {
+		_, err := h.Repo.LinkTelegramChat(telegramCodeHash(code), chatID, title, time.Now())
+		switch {
+		case err == nil:
+			reply = "Bu sohbet hesabınıza bağlandı; artık abonelikleriniz için seçebilirsiniz.\n" +
+				"This chat is now linked to your account; you can choose it for your subscriptions."
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			reply = "Bağlantı kodu geçersiz veya süresi dolmuş; uygulamadan yeni bir kod alın.\n" +
+				"The link code is invalid or expired; get a new one from the app."
+		default:
+			log.Printf("telegram link for chat %s failed: %v", chatID, err)
+			return c.SendStatus(fiber.StatusInternalServerError) // Telegram tekrar dener
+		}
+	}
+	if h.TelegramReply != nil {
+		if err := h.TelegramReply(chatID, reply); err != nil {
+			log.Printf("telegram reply to chat %s failed: %v", chatID, err)
+		}
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// nameTaken reports whether another subscription (other than exceptID) of the user has name.
+func (h *Handler) nameTaken(userID uint, name string, exceptID uint) (bool, error) {
+	notifications, err := h.Repo.ListByUser(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range notifications {
+		if n.ID != exceptID && strings.EqualFold(n.Name, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Unsubscribe handles the signed link from emails; no login needed. GET shows
+// a confirmation page (link scanners must not unsubscribe anyone), POST
+// unsubscribes. Mail clients POST "List-Unsubscribe=One-Click" (RFC 8058).
+func (h *Handler) Unsubscribe(c *fiber.Ctx) error {
+	token := c.Query("token")
+	id, _, err := h.Tokens.Verify(PurposeUnsubscribe, token)
+	if err != nil {
+		return h.page(c, fiber.StatusBadRequest, DefaultLocale, "invalid_link", nil)
+	}
+
+	n, err := h.Repo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Abonelik silinmişse zaten mesaj gitmiyor
+		return h.page(c, fiber.StatusOK, DefaultLocale, "unsubscribed", pageData{})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	data := pageData{Place: placeName(*n), Token: token}
+
+	if c.Method() == fiber.MethodGet {
+		return h.page(c, fiber.StatusOK, n.EmailLocale(), "unsubscribe_confirm", data)
+	}
+
+	if err := h.Repo.Unsubscribe(n.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return h.page(c, fiber.StatusOK, n.EmailLocale(), "unsubscribed", data)
+}
+
+// VerifyEmail confirms a subscription address from the signed link in the
+// verification email. Like Unsubscribe, GET only shows a confirmation page.
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	id, bind, err := h.Tokens.Verify(PurposeVerifyEmail, token)
+	if err != nil {
+		return h.page(c, fiber.StatusBadRequest, DefaultLocale, "invalid_link", nil)
+	}
+
+	n, err := h.Repo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return h.page(c, fiber.StatusBadRequest, DefaultLocale, "invalid_link", nil)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	// Bağlantı gönderildikten sonra adres değiştiyse eski bağlantı geçersiz
+	if bind != emailBinding(n.Email) {
+		return h.page(c, fiber.StatusBadRequest, n.EmailLocale(), "invalid_link", nil)
+	}
+	data := pageData{Place: placeName(*n), Token: token, Email: n.Email}
+
+	if c.Method() == fiber.MethodGet && n.EmailVerifiedAt == nil {
+		return h.page(c, fiber.StatusOK, n.EmailLocale(), "verify_confirm", data)
+	}
+	if n.EmailVerifiedAt == nil {
+		if err := h.Repo.MarkEmailVerified(n.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+		}
+	}
+	return h.page(c, fiber.StatusOK, n.EmailLocale(), "verified", data)
+}
+
+func (h *Handler) page(c *fiber.Ctx, status int, locale, name string, data any) error {
+	html, err := renderPage(locale, name, data)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Template error"})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(status).SendString(html)
 }
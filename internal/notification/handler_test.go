@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestResendVerification(t *testing.T) {
+	repo := newTestRepo(t)
+	var sent []string
+	h := &Handler{Repo: repo, SendVerification: func(n Notification) error {
+		sent = append(sent, n.Email)
+		return nil
+	}}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", uint(1))
+		return c.Next()
+	})
+	app.Post("/notifications/:id/verify/resend", h.ResendVerification)
+
+	resend := func(id uint) int {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("POST", fmt.Sprintf("/notifications/%d/verify/resend", id), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	// İlk e-posta kaybolmuş ve bekleme süresi geçmiş
+	old := time.Now().Add(-2 * verificationCooldown)
+	lost := Notification{UserID: 1, Name: "lost", Email: "a@example.com", VerificationSentTo: "a@example.com", VerificationSentAt: &old}
+	verifiedAt := time.Now()
+	verified := Notification{UserID: 1, Name: "verified", Email: "b@example.com", EmailVerifiedAt: &verifiedAt}
+	other := Notification{UserID: 2, Name: "other", Email: "c@example.com"}
+	for _, n := range []*Notification{&lost, &verified, &other} {
+		if err := repo.DB.Create(n).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lost.needsVerification(time.Now()) {
+		t.Fatal("saving must not mail the same address again on its own")
+	}
+
+	if got := resend(lost.ID); got != fiber.StatusAccepted {
+		t.Fatalf("resend after the cooldown: status %d, want %d", got, fiber.StatusAccepted)
+	}
+	if got := resend(lost.ID); got != fiber.StatusTooManyRequests {
+		t.Errorf("resend within the cooldown: status %d, want %d", got, fiber.StatusTooManyRequests)
+	}
+	if got := resend(verified.ID); got != fiber.StatusConflict {
+		t.Errorf("resend for a confirmed address: status %d, want %d", got, fiber.StatusConflict)
+	}
+	if got := resend(other.ID); got != fiber.StatusNotFound {
+		t.Errorf("resend for another user's subscription: status %d, want %d", got, fiber.StatusNotFound)
+	}
+	if len(sent) != 1 || sent[0] != lost.Email {
+		t.Errorf("sent %v, want one mail to %s", sent, lost.Email)
+	}
+
+	reloaded, err := repo.FindByID(lost.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.VerificationSentAt == nil || !reloaded.VerificationSentAt.After(old) {
+		t.Errorf("VerificationSentAt = %v, want the resend time", reloaded.VerificationSentAt)
+	}
+}
@@ -1,9 +1,15 @@
 package notification
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -18,6 +24,10 @@ type SMTPConfig struct {
 
 type Mailer struct {
 	cfg SMTPConfig
+
+	// tokens and baseURL build the unsubscribe links; links are left out while unset
+	tokens  *TokenSigner
+	baseURL string
 }
 
 func NewMailer(cfg SMTPConfig) (*Mailer, error) {
@@ -30,43 +40,147 @@ func NewMailer(cfg SMTPConfig) (*Mailer, error) {
 	return &Mailer{cfg: cfg}, nil
 }
 
-func (m *Mailer) SendAQIAlert(to, riskLevel string, aqi int) error {
+// UseLinks enables signed links: a one-click unsubscribe link (body and
+// List-Unsubscribe header) in every message and address verification mails.
+// baseURL is the public API root.
+func (m *Mailer) UseLinks(tokens *TokenSigner, baseURL string) {
+	m.tokens = tokens
+	m.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+// unsubscribeURL returns the signed link for subscription n, or "" when links are off.
+func (m *Mailer) unsubscribeURL(n Notification) (string, error) {
+	if m.tokens == nil || m.baseURL == "" || n.ID == 0 {
+		return "", nil
+	}
+	token, err := m.tokens.Sign(PurposeUnsubscribe, n.ID, "")
+	if err != nil {
+		return "", fmt.Errorf("sign unsubscribe token: %w", err)
+	}
+	return m.baseURL + "/notifications/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+// SendAQIAlert sends an alert, all-clear or deferred batch for one
+// subscription, rendered in the subscription's locale.
+func (m *Mailer) SendAQIAlert(to string, alert Alert) error {
 	if to == "" {
 		return errors.New("recipient email is empty")
 	}
 
-	if riskLevel == "" {
-		riskLevel = "unknown"
+	kind := alert.Kind
+	if kind == "" {
+		kind = KindAlert
 	}
+	data := alertData(alert)
+	link, err := m.unsubscribeURL(alert.Subscription)
+	if err != nil {
+		return err
+	}
+	data.UnsubscribeURL = link
 
-	subject := fmt.Sprintf("Hava Kalitesi Uyarısı: %s", strings.ToUpper(riskLevel))
-	body := fmt.Sprintf(
-		"Merhaba,\n\nBulunduğunuz konumdaki hava kalitesi uyarı seviyesine ulaştı.\n\nRisk Durumu: %s\n\nLütfen gerekli önlemleri alınız ve mümkünse dışarı çıkmayınız.\n\nSevgiler,\nClean Breathing",
-		strings.ToUpper(riskLevel),
-	)
+	subject, text, html, err := renderEmail(alert.Subscription.EmailLocale(), kind, data)
+	if err != nil {
+		return err
+	}
+	return m.sendMail(to, subject, text, html, link)
+}
+
+// SendVerification asks the owner of n.Email to confirm the address.
+func (m *Mailer) SendVerification(n Notification) error {
+	if n.Email == "" {
+		return errors.New("recipient email is empty")
+	}
+	if m.tokens == nil || m.baseURL == "" {
+		return errors.New("verification links need a public base URL")
+	}
+	token, err := m.tokens.Sign(PurposeVerifyEmail, n.ID, emailBinding(n.Email))
+	if err != nil {
+		return fmt.Errorf("sign verification token: %w", err)
+	}
 
-	return m.sendMail(to, subject, body)
+	data := emailData{Place: placeName(n), VerifyURL: m.baseURL + "/notifications/verify?token=" + url.QueryEscape(token)}
+	subject, text, html, err := renderEmail(n.EmailLocale(), "verify", data)
+	if err != nil {
+		return err
+	}
+	return m.sendMail(n.Email, subject, text, html, "")
+}
+
+// SendDigest sends a daily or weekly summary.
+func (m *Mailer) SendDigest(to string, d Digest) error {
+	if to == "" {
+		return errors.New("recipient email is empty")
+	}
+
+	data := digestEmailData(d)
+	link, err := m.unsubscribeURL(d.Subscription)
+	if err != nil {
+		return err
+	}
+	data.UnsubscribeURL = link
+
+	subject, text, html, err := renderEmail(d.Subscription.EmailLocale(), "digest", data)
+	if err != nil {
+		return err
+	}
+	return m.sendMail(to, subject, text, html, link)
 }
 
-func (m *Mailer) sendMail(to, subject, body string) error {
+// sendMail sends a multipart/alternative message with a plain-text and an HTML
+// part. With an unsubscribe link it adds the RFC 8058 one-click headers.
+func (m *Mailer) sendMail(to, subject, text, html, unsubscribeURL string) error {
 	addr := fmt.Sprintf("%s:%d", m.cfg.Host, m.cfg.Port)
 
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
 	headers := []string{
 		fmt.Sprintf("From: %s", m.cfg.From),
 		fmt.Sprintf("To: %s", to),
-		fmt.Sprintf("Subject: %s", subject),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", subject)),
 		"MIME-Version: 1.0",
-		"Content-Type: text/plain; charset=utf-8",
-		"",
 	}
-	msg := strings.Join(headers, "\r\n") + body
+	if unsubscribeURL != "" {
+		headers = append(headers,
+			fmt.Sprintf("List-Unsubscribe: <%s>", unsubscribeURL),
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+	headers = append(headers,
+		fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q", mw.Boundary()),
+		"",
+		"",
+	)
+	msg := append([]byte(strings.Join(headers, "\r\n")), body.Bytes()...)
 
 	var auth smtp.Auth
 	if m.cfg.Username != "" {
 		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
 	}
 
-	return smtp.SendMail(addr, auth, m.cfg.From, []string{to}, []byte(msg))
+	return smtp.SendMail(addr, auth, m.cfg.From, []string{to}, msg)
 }
 
 func ConfigFromEnv(host, port, username, password, from string) (SMTPConfig, error) {
@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// legacyUserIndex is the unique index that limited users to one subscription.
+const legacyUserIndex = "idx_notifications_user_id"
+
+// DropLegacyUserIndex removes the old unique index on user_id so a user can
+// own several subscriptions. AutoMigrate never drops indexes, so this runs
+// before it; it is a no-op once the index is gone.
+func DropLegacyUserIndex(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&Notification{}) || !m.HasIndex(&Notification{}, legacyUserIndex) {
+		return nil
+	}
+	log.Printf("Dropping legacy unique index %s", legacyUserIndex)
+	return m.DropIndex(&Notification{}, legacyUserIndex)
+}
+
+// TrustOwnEmails confirms subscriptions created before double opt-in whose
+// address is the owner's sign-in (Google) address. Other addresses have to be
+// confirmed through the verification link; see SendPendingVerifications.
+func TrustOwnEmails(db *gorm.DB) error {
+	return db.Exec(`UPDATE notifications SET email_verified_at = NOW()
+		FROM users
+		WHERE notifications.user_id = users.id
+		  AND notifications.email_verified_at IS NULL
+		  AND LOWER(notifications.email) = LOWER(users.email)`).Error
+}
+
+// SendPendingVerifications mails the confirmation link to unconfirmed
+// addresses that never got one, i.e. subscriptions from before double
+// opt-in whose address isn't the owner's sign-in address. They get no alerts
+// until confirmed, so this runs once the mailer is up.
+func SendPendingVerifications(repo *Repository, send func(Notification) error) {
+	pending, err := repo.PendingVerifications()
+	if err != nil {
+		log.Printf("pending verifications: %v", err)
+		return
+	}
+	now := time.Now()
+	sent := 0
+	for i := range pending {
+		n := &pending[i]
+		if !n.HasChannel(ChannelEmail) {
+			continue
+		}
+		if err := sendVerification(repo, n, now, send); err != nil {
+			log.Printf("verification email for subscription %d failed: %v", n.ID, err)
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("Sent %d verification emails to subscriptions from before double opt-in", sent)
+	}
+}
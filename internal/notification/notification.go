@@ -1,10 +1,86 @@
 package notification
 
+import (
+	"slices"
+	"time"
+)
+
+// DefaultName is used for subscriptions created without a name (and for
+// the single subscription users had before names existed).
+const DefaultName = "Default"
+
+// MaxPerUser caps how many locations one user can watch.
+const MaxPerUser = 10
+
+// Delivery channels; new channels are added to Channels.
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook" // signed JSON POST, see webhook.go
+	ChannelPush     = "push"    // Web Push to the owner's browsers, see webpush.go
+	ChannelSMS      = "sms"     // hazardous alerts only, to the owner's phone, see sms.go
+	ChannelSlack    = "slack"   // chat channels, see chat.go
+	ChannelDiscord  = "discord"
+	ChannelTelegram = "telegram"
+)
+
+// Channels lists the delivery channels a subscription may use.
+var Channels = []string{ChannelEmail, ChannelWebhook, ChannelPush, ChannelSMS, ChannelSlack, ChannelDiscord, ChannelTelegram}
+
 type Notification struct {
-	ID        uint `gorm:"primaryKey"`
-	UserID    uint `gorm:"uniqueIndex"`
-	Latitude  float64
-	Longitude float64
-	Threshold int
-	Email     string
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"uniqueIndex:idx_notifications_user_name" json:"-"`
+	Name      string    `gorm:"size:64;not null;default:'Default';uniqueIndex:idx_notifications_user_name" json:"name"`
+	Type      string    `gorm:"size:16;not null;default:'alert';index" json:"type"` // alert or digest
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	// Threshold is read according to ThresholdKind (see threshold.go)
+	Threshold     int    `json:"threshold"`
+	ThresholdKind string `gorm:"size:16" json:"threshold_kind"`
+	AQIStandard   string `gorm:"size:16" json:"aqi_standard,omitempty"`
+	Pollutant     string `gorm:"size:16" json:"pollutant,omitempty"`
+	MinRisk       string `gorm:"size:16" json:"min_risk,omitempty"`
+	AllClear      bool   `json:"all_clear"` // also notify when the air recovers after an alert
+	// Sessiz saatler abonenin kendi saat diliminde yorumlanır
+	Timezone          string `gorm:"size:64" json:"timezone,omitempty"` // IANA name, UTC if empty
+	QuietStart        string `gorm:"size:5" json:"quiet_start,omitempty"`
+	QuietEnd          string `gorm:"size:5" json:"quiet_end,omitempty"`
+	QuietBreakthrough bool   `json:"quiet_breakthrough"` // hazardous alerts ignore quiet hours
+	// Özet (digest) ayarları; yalnızca Type == digest için
+	DigestFrequency string     `gorm:"size:16" json:"digest_frequency,omitempty"` // daily or weekly
+	DigestTime      string     `gorm:"size:5" json:"digest_time,omitempty"`       // local HH:MM
+	DigestWeekday   int        `json:"digest_weekday"`                            // weekly digests, 0 = Sunday
+	LastDigestAt    *time.Time `json:"last_digest_at,omitempty"`
+	Locale          string     `gorm:"size:8" json:"locale"` // email language: tr or en
+	Email           string     `json:"email"`
+	// Double opt-in: alerts are only mailed to confirmed addresses
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Last verification mail: the address it went to and when (see needsVerification)
+	VerificationSentTo string     `gorm:"size:255;not null;default:''" json:"-"`
+	VerificationSentAt *time.Time `json:"-"`
+	Channels           []string   `gorm:"type:jsonb;serializer:json" json:"channels"`
+	WebhookURL         string     `gorm:"size:512" json:"webhook_url,omitempty"`
+	// Generated when the webhook channel is enabled; only ever shown to the owner
+	WebhookSecret     string `gorm:"size:64" json:"webhook_secret,omitempty"`
+	SlackWebhookURL   string `gorm:"size:512" json:"slack_webhook_url,omitempty"`
+	DiscordWebhookURL string `gorm:"size:512" json:"discord_webhook_url,omitempty"`
+	TelegramChatID    string `gorm:"size:64" json:"telegram_chat_id,omitempty"`
+	// Set by the one-click unsubscribe link; saving the subscription again re-enables it
+	UnsubscribedAt *time.Time `json:"unsubscribed_at,omitempty"`
+}
+
+// HasChannel reports whether alerts for n go out on channel. Subscriptions
+// without channels (created before channels existed) use email.
+func (n Notification) HasChannel(channel string) bool {
+	if len(n.Channels) == 0 {
+		return channel == ChannelEmail
+	}
+	return slices.Contains(n.Channels, channel)
+}
+
+// CanEmail reports whether alerts for n may be mailed: email is one of its
+// channels and the address has been confirmed.
+func (n Notification) CanEmail() bool {
+	return n.HasChannel(ChannelEmail) && n.Email != "" && n.EmailVerifiedAt != nil
 }
@@ -0,0 +1,280 @@
+package notification
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox statuses. A row is pending until a worker claims it (sending), then
+// ends up sent, back in pending with a later NextAttemptAt, or dead after
+// too many attempts. Rows of deleted or unsubscribed subscriptions are cancelled.
+const (
+	OutboxPending   = "pending"
+	OutboxSending   = "sending"
+	OutboxSent      = "sent"
+	OutboxDead      = "dead"
+	OutboxCancelled = "cancelled"
+)
+
+// OutboxMessage is one alert waiting to be delivered on one channel, so a
+// failing channel is retried without repeating the others.
+type OutboxMessage struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	NotificationID uint       `gorm:"index" json:"notification_id"`
+	Channel        string     `gorm:"size:16;not null" json:"channel"`
+	Alert          Alert      `gorm:"type:jsonb;serializer:json" json:"-"`
+	Kind           string     `gorm:"size:16" json:"kind"`
+	Status         string     `gorm:"size:16;not null;default:'pending';index:idx_notification_outbox_due,priority:1" json:"status"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_notification_outbox_due,priority:2" json:"next_attempt_at"` // lease expiry while sending
+	Attempts       int        `json:"attempts"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+}
+
+func (OutboxMessage) TableName() string { return "notification_outbox" }
+
+// OutboxPolicy controls delivery retries. The wait after the n-th failed
+// attempt is Backoff * 2^(n-1), capped at MaxBackoff.
+type OutboxPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Lease       time.Duration // a claimed row is retried after this if its worker died
+	Retention   time.Duration // sent and cancelled rows are purged after this
+}
+
+func (p OutboxPolicy) backoff(attempts int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+// Enqueue stores one outbox row per channel of alert.
+func (r *Repository) Enqueue(alert Alert, channels []string) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	now := time.Now()
+	msgs := make([]OutboxMessage, len(channels))
+	for i, ch := range channels {
+		msgs[i] = OutboxMessage{
+			NotificationID: alert.Subscription.ID,
+			Channel:        ch,
+			Alert:          alert,
+			Kind:           alert.Kind,
+			Status:         OutboxPending,
+			NextAttemptAt:  now,
+		}
+	}
+	return r.DB.Create(&msgs).Error
+}
+
+// ClaimOutbox locks up to limit due rows, marks them sending and leases them
+// until now+lease. SKIP LOCKED lets several workers (and instances) claim
+// disjoint rows without waiting for each other.
+func (r *Repository) ClaimOutbox(limit int, lease time.Duration) ([]OutboxMessage, error) {
+	var msgs []OutboxMessage
+	now := time.Now()
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []string{OutboxPending, OutboxSending}, now).
+			Order("next_attempt_at").Limit(limit).Find(&msgs).Error
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(msgs))
+		for i := range msgs {
+			ids[i] = msgs[i].ID
+			msgs[i].Status = OutboxSending
+			msgs[i].Attempts++
+			msgs[i].NextAttemptAt = now.Add(lease)
+		}
+		return tx.Model(&OutboxMessage{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":          OutboxSending,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(lease),
+			"updated_at":      now,
+		}).Error
+	})
+	return msgs, err
+}
+
+// RenewOutboxLease extends the lease of a claimed row before delivery. It
+// reports false if the row is no longer ours: the lease ran out and another
+// worker claimed it again (attempts moved on) or it was finished.
+func (r *Repository) RenewOutboxLease(msg *OutboxMessage, lease time.Duration) (bool, error) {
+	now := time.Now()
+	res := r.DB.Model(&OutboxMessage{}).
+		Where("id = ? AND status = ? AND attempts = ?", msg.ID, OutboxSending, msg.Attempts).
+		Updates(map[string]any{"next_attempt_at": now.Add(lease), "updated_at": now})
+	return res.RowsAffected > 0, res.Error
+}
+
+// FinishOutbox records the result of one delivery attempt. Like
+// RenewOutboxLease it only touches the row while this claim still owns it,
+// so a slow worker can't overwrite the result of the worker that took over.
+func (r *Repository) FinishOutbox(msg *OutboxMessage) (bool, error) {
+	res := r.DB.Model(&OutboxMessage{}).
+		Where("id = ? AND status = ? AND attempts = ?", msg.ID, OutboxSending, msg.Attempts).
+		Updates(map[string]any{
+			"status":          msg.Status,
+			"next_attempt_at": msg.NextAttemptAt,
+			"last_error":      msg.LastError,
+			"sent_at":         msg.SentAt,
+			"updated_at":      time.Now(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ListOutbox returns the newest rows with status, at most limit.
+func (r *Repository) ListOutbox(status string, limit int) ([]OutboxMessage, error) {
+	var msgs []OutboxMessage
+	err := r.DB.Where("status = ?", status).Order("id DESC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// RequeueOutbox moves dead rows back to pending with a fresh attempt budget.
+// Without ids every dead row is requeued. It returns how many rows moved.
+func (r *Repository) RequeueOutbox(ids []uint) (int64, error) {
+	q := r.DB.Model(&OutboxMessage{}).Where("status = ?", OutboxDead)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	res := q.Updates(map[string]any{
+		"status":          OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"updated_at":      time.Now(),
+	})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeOutbox deletes finished rows older than before; dead rows stay for requeueing.
+func (r *Repository) PurgeOutbox(before time.Time) error {
+	return r.DB.Where("status IN ? AND updated_at < ?", []string{OutboxSent, OutboxCancelled}, before).
+		Delete(&OutboxMessage{}).Error
+}
+
+// StartOutboxWorkers runs workers goroutines that claim due outbox rows every
+// interval and hand them to deliver. The subscription is reloaded before
+// delivery so unsubscribes and address changes made in the meantime apply.
+func StartOutboxWorkers(
+	repo *Repository,
+	workers int,
+	interval time.Duration,
+	policy OutboxPolicy,
+	deliver func(channel string, alert Alert) error,
+) {
+	if workers <= 0 {
+		workers = 1
+	}
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 30 * time.Second
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = max(time.Hour, policy.Backoff)
+	}
+	if policy.Lease <= 0 {
+		policy.Lease = 5 * time.Minute
+	}
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for {
+				// Dolu bir tur sonrası beklemeden devam et
+				if n := drainOutbox(repo, policy, deliver); n == 0 {
+					time.Sleep(interval)
+				}
+			}
+		}()
+	}
+
+	if policy.Retention > 0 {
+		go func() {
+			for {
+				if err := repo.PurgeOutbox(time.Now().Add(-policy.Retention)); err != nil {
+					log.Println("Outbox purge error:", err)
+				}
+				time.Sleep(time.Hour)
+			}
+		}()
+	}
+}
+
+// outboxBatch is how many rows a worker claims at once. Each row's lease is
+// renewed right before its delivery, so a batch may take longer than Lease.
+const outboxBatch = 5
+
+// drainOutbox claims and delivers one batch and returns its size.
+func drainOutbox(repo *Repository, policy OutboxPolicy, deliver func(string, Alert) error) int {
+	msgs, err := repo.ClaimOutbox(outboxBatch, policy.Lease)
+	if err != nil {
+		log.Println("Outbox claim error:", err)
+		return 0
+	}
+
+	for i := range msgs {
+		msg := &msgs[i]
+		if owned, err := repo.RenewOutboxLease(msg, policy.Lease); err != nil || !owned {
+			if err != nil {
+				log.Printf("Outbox lease error for message %d: %v", msg.ID, err)
+			}
+			continue // başka bir worker devraldı; ikinci kez gönderme
+		}
+		err := deliverOutbox(repo, msg, deliver)
+		now := time.Now()
+		switch {
+		case errors.Is(err, errSubscriptionGone):
+			msg.Status, msg.LastError = OutboxCancelled, err.Error()
+		case err == nil:
+			msg.Status, msg.LastError, msg.SentAt = OutboxSent, "", &now
+		case msg.Attempts >= policy.MaxAttempts:
+			msg.Status, msg.LastError = OutboxDead, err.Error()
+			log.Printf("Outbox message %d (%s, subscription %d) dead after %d attempts: %v", msg.ID, msg.Channel, msg.NotificationID, msg.Attempts, err)
+		default:
+			msg.Status, msg.LastError = OutboxPending, err.Error()
+			msg.NextAttemptAt = now.Add(policy.backoff(msg.Attempts))
+			log.Printf("Outbox message %d (%s, subscription %d) attempt %d failed, retrying at %s: %v", msg.ID, msg.Channel, msg.NotificationID, msg.Attempts, msg.NextAttemptAt.Format(time.RFC3339), err)
+		}
+		if owned, err := repo.FinishOutbox(msg); err != nil {
+			log.Printf("Outbox update error for message %d: %v", msg.ID, err)
+		} else if !owned {
+			log.Printf("Outbox message %d was reclaimed during delivery; result %s dropped", msg.ID, msg.Status)
+		}
+	}
+	return len(msgs)
+}
+
+var errSubscriptionGone = errors.New("subscription deleted, unsubscribed or channel removed")
+
+func deliverOutbox(repo *Repository, msg *OutboxMessage, deliver func(string, Alert) error) error {
+	n, err := repo.FindByID(msg.NotificationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errSubscriptionGone
+	}
+	if err != nil {
+		return err
+	}
+	if n.UnsubscribedAt != nil || !n.HasChannel(msg.Channel) {
+		return errSubscriptionGone
+	}
+
+	alert := msg.Alert
+	alert.Subscription = *n
+	return deliver(msg.Channel, alert)
+}
@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	p := OutboxPolicy{Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute}
+	want := []time.Duration{
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		10 * time.Minute, 10 * time.Minute,
+	}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff after attempt %d = %s, want %s", i+1, got, w)
+		}
+	}
+	if got := p.backoff(100); got != p.MaxBackoff {
+		t.Errorf("backoff after attempt 100 = %s, want the cap %s", got, p.MaxBackoff)
+	}
+}
+
+// newOutboxRow stores an email subscription and one pending outbox row for it.
+func newOutboxRow(t *testing.T, repo *Repository) Notification {
+	t.Helper()
+	n := Notification{UserID: 1, Name: "Home", Email: "a@example.com", Channels: []string{ChannelEmail}}
+	if err := repo.DB.Create(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Enqueue(Alert{Kind: KindAlert, Subscription: n}, []string{ChannelEmail}); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestOutboxStaleLeaseRejected(t *testing.T) {
+	repo := newTestRepo(t)
+	newOutboxRow(t, repo)
+
+	lease := 20 * time.Millisecond
+	first, err := repo.ClaimOutbox(outboxBatch, lease)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("first claim: %d row(s), %v", len(first), err)
+	}
+	if again, _ := repo.ClaimOutbox(outboxBatch, lease); len(again) != 0 {
+		t.Fatal("leased row claimed twice")
+	}
+
+	// İlk worker takıldı; kira doldu ve satırı başka bir worker aldı
+	time.Sleep(2 * lease)
+	second, err := repo.ClaimOutbox(outboxBatch, time.Minute)
+	if err != nil || len(second) != 1 {
+		t.Fatalf("claim after the lease expired: %d row(s), %v", len(second), err)
+	}
+
+	stale := &first[0]
+	if owned, err := repo.RenewOutboxLease(stale, time.Minute); err != nil || owned {
+		t.Errorf("stale lease renewed: owned %v, %v", owned, err)
+	}
+	stale.Status = OutboxSent
+	if owned, err := repo.FinishOutbox(stale); err != nil || owned {
+		t.Errorf("stale claim finished the row: owned %v, %v", owned, err)
+	}
+
+	current := &second[0]
+	now := time.Now()
+	current.Status, current.SentAt = OutboxSent, &now
+	if owned, err := repo.FinishOutbox(current); err != nil || !owned {
+		t.Errorf("current claim rejected: owned %v, %v", owned, err)
+	}
+}
+
+func TestOutboxDeadAfterMaxAttempts(t *testing.T) {
+	repo := newTestRepo(t)
+	newOutboxRow(t, repo)
+
+	policy := OutboxPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Lease: time.Minute}
+	attempts := 0
+	deliver := func(string, Alert) error {
+		attempts++
+		return errors.New("smtp: connection refused")
+	}
+
+	for i := 0; i < policy.MaxAttempts+2; i++ {
+		drainOutbox(repo, policy, deliver)
+		time.Sleep(5 * time.Millisecond) // bekleme süresi dolsun
+	}
+	if attempts != policy.MaxAttempts {
+		t.Errorf("delivered %d times, want %d", attempts, policy.MaxAttempts)
+	}
+
+	dead, err := repo.ListOutbox(OutboxDead, 10)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("dead rows: %d, %v", len(dead), err)
+	}
+	if dead[0].Attempts != policy.MaxAttempts || dead[0].LastError == "" {
+		t.Errorf("dead row: %d attempts, last error %q", dead[0].Attempts, dead[0].LastError)
+	}
+
+	// Elle yeniden kuyruğa alınan satır yeni bir deneme hakkıyla gönderilir
+	if moved, err := repo.RequeueOutbox(nil); err != nil || moved != 1 {
+		t.Fatalf("requeue: %d, %v", moved, err)
+	}
+	if n := drainOutbox(repo, policy, func(string, Alert) error { return nil }); n != 1 {
+		t.Fatalf("requeued row not claimed (%d)", n)
+	}
+	if sent, _ := repo.ListOutbox(OutboxSent, 10); len(sent) != 1 || sent[0].Attempts != 1 {
+		t.Errorf("sent rows after requeue: %+v", sent)
+	}
+}
+
+func TestOutboxCancelledForRemovedChannel(t *testing.T) {
+	repo := newTestRepo(t)
+	n := newOutboxRow(t, repo)
+	n.Channels = []string{ChannelPush}
+	if err := repo.DB.Save(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := false
+	drainOutbox(repo, OutboxPolicy{MaxAttempts: 3, Lease: time.Minute}, func(string, Alert) error {
+		delivered = true
+		return nil
+	})
+	if delivered {
+		t.Error("delivered on a channel the subscription no longer has")
+	}
+	if cancelled, _ := repo.ListOutbox(OutboxCancelled, 10); len(cancelled) != 1 {
+		t.Errorf("%d cancelled row(s), want 1", len(cancelled))
+	}
+}
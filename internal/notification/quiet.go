@@ -0,0 +1,74 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+)
+
+// quietClock is the format of QuietStart and QuietEnd.
+const quietClock = "15:04"
+
+// Location returns the subscription's timezone, UTC when unset or invalid.
+func (n Notification) Location() *time.Location {
+	if n.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(n.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ValidateQuietHours checks the timezone and the quiet window.
+func (n Notification) ValidateQuietHours() error {
+	if n.Timezone != "" {
+		if _, err := time.LoadLocation(n.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", n.Timezone)
+		}
+	}
+	if (n.QuietStart == "") != (n.QuietEnd == "") {
+		return fmt.Errorf("quiet_start and quiet_end must be set together")
+	}
+	for _, v := range []string{n.QuietStart, n.QuietEnd} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(quietClock, v); err != nil {
+			return fmt.Errorf("quiet hours must be HH:MM, got %q", v)
+		}
+	}
+	return nil
+}
+
+// InQuietHours reports whether t falls in the subscription's quiet window,
+// in its own timezone. Windows may wrap midnight (22:00-07:00).
+func (n Notification) InQuietHours(t time.Time) bool {
+	if n.QuietStart == "" || n.QuietEnd == "" || n.QuietStart == n.QuietEnd {
+		return false
+	}
+	start, err1 := time.Parse(quietClock, n.QuietStart)
+	end, err2 := time.Parse(quietClock, n.QuietEnd)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	local := t.In(n.Location())
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// DeferredAlert is an alert held back during quiet hours.
+type DeferredAlert struct {
+	ID             uint `gorm:"primaryKey"`
+	CreatedAt      time.Time
+	NotificationID uint  `gorm:"index"`
+	Alert          Alert `gorm:"type:jsonb;serializer:json"`
+}
+
+func (DeferredAlert) TableName() string { return "notification_deferred_alerts" }
@@ -0,0 +1,129 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"nasa-app/internal/airquality"
+)
+
+func TestInQuietHours(t *testing.T) {
+	utc := func(s string) time.Time {
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	tests := []struct {
+		name        string
+		tz          string
+		start, end  string
+		at          string
+		wantInQuiet bool
+	}{
+		{"no window", "", "", "", "2026-01-10T23:00:00Z", false},
+		{"same start and end", "", "22:00", "22:00", "2026-01-10T22:00:00Z", false},
+		{"day window inside", "", "09:00", "17:00", "2026-01-10T12:00:00Z", true},
+		{"day window end is exclusive", "", "09:00", "17:00", "2026-01-10T17:00:00Z", false},
+
+		// 22:00-07:00 gece yarısını geçer
+		{"overnight before midnight", "", "22:00", "07:00", "2026-01-10T23:30:00Z", true},
+		{"overnight after midnight", "", "22:00", "07:00", "2026-01-11T03:00:00Z", true},
+		{"overnight start", "", "22:00", "07:00", "2026-01-10T22:00:00Z", true},
+		{"overnight end", "", "22:00", "07:00", "2026-01-11T07:00:00Z", false},
+		{"overnight daytime", "", "22:00", "07:00", "2026-01-10T12:00:00Z", false},
+
+		// Local time: Istanbul is UTC+3 all year
+		{"timezone applied", "Europe/Istanbul", "22:00", "07:00", "2026-01-10T20:00:00Z", true},
+		{"timezone applied, outside", "Europe/Istanbul", "22:00", "07:00", "2026-01-10T18:30:00Z", false},
+
+		// Berlin springs forward on 2026-03-29 at 02:00 CET -> 03:00 CEST
+		{"dst spring, before the jump", "Europe/Berlin", "01:30", "02:30", "2026-03-29T00:45:00Z", true}, // 01:45 CET
+		{"dst spring, after the jump", "Europe/Berlin", "01:30", "02:30", "2026-03-29T01:15:00Z", false}, // 03:15 CEST
+		{"dst spring, overnight", "Europe/Berlin", "22:00", "07:00", "2026-03-29T04:30:00Z", true},       // 06:30 CEST
+		// and falls back on 2026-10-25 at 03:00 CEST -> 02:00 CET, so 02:30 happens twice
+		{"dst autumn, first 02:30", "Europe/Berlin", "02:00", "03:00", "2026-10-25T00:30:00Z", true},
+		{"dst autumn, second 02:30", "Europe/Berlin", "02:00", "03:00", "2026-10-25T01:30:00Z", true},
+		{"dst autumn, 03:00 CET", "Europe/Berlin", "02:00", "03:00", "2026-10-25T02:00:00Z", false},
+
+		// Unknown timezones fall back to UTC
+		{"invalid timezone", "Mars/Olympus_Mons", "22:00", "07:00", "2026-01-10T23:00:00Z", true},
+		{"invalid timezone, outside", "Mars/Olympus_Mons", "22:00", "07:00", "2026-01-10T12:00:00Z", false},
+	}
+	for _, tt := range tests {
+		n := Notification{Timezone: tt.tz, QuietStart: tt.start, QuietEnd: tt.end}
+		if got := n.InQuietHours(utc(tt.at)); got != tt.wantInQuiet {
+			t.Errorf("%s: InQuietHours(%s) = %v, want %v", tt.name, tt.at, got, tt.wantInQuiet)
+		}
+	}
+}
+
+func TestValidateQuietHours(t *testing.T) {
+	valid := []Notification{
+		{},
+		{Timezone: "Europe/Istanbul", QuietStart: "22:00", QuietEnd: "07:00"},
+	}
+	for _, n := range valid {
+		if err := n.ValidateQuietHours(); err != nil {
+			t.Errorf("%+v: %v", n, err)
+		}
+	}
+	invalid := []Notification{
+		{Timezone: "Mars/Olympus_Mons"},
+		{QuietStart: "22:00"},
+		{QuietStart: "25:00", QuietEnd: "07:00"},
+		{QuietStart: "10pm", QuietEnd: "7am"},
+	}
+	for _, n := range invalid {
+		if err := n.ValidateQuietHours(); err == nil {
+			t.Errorf("%+v accepted", n)
+		}
+	}
+}
+
+// Alerts held for a subscription that is deleted, unsubscribed or no longer
+// scanned must not stay in the table.
+func TestDeferredAlertsOfRemovedSubscriptions(t *testing.T) {
+	repo := newTestRepo(t)
+	now := time.Now().UTC()
+	subs := []Notification{
+		{UserID: 1, Name: "deleted"},
+		{UserID: 1, Name: "unsubscribed"},
+		{UserID: 1, Name: "digest", Type: TypeDigest},
+		{UserID: 1, Name: "kept", QuietStart: now.Add(-time.Hour).Format(quietClock), QuietEnd: now.Add(time.Hour).Format(quietClock)},
+	}
+	for i := range subs {
+		if err := repo.DB.Create(&subs[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		alert := Alert{Kind: KindAlert, Metrics: airquality.Metrics{PM25: 80}}
+		if err := repo.DeferAlert(&DeferredAlert{NotificationID: subs[i].ID, Alert: alert}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.DeleteNotification(1, subs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Unsubscribe(subs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	held, _ := repo.DeferredAlerts()
+	if len(held[subs[0].ID]) != 0 || len(held[subs[1].ID]) != 0 {
+		t.Errorf("deferred alerts left after delete/unsubscribe: %v", held)
+	}
+
+	active, err := repo.ListByType(TypeAlert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flushDeferred(repo, active, func(Alert) error {
+		t.Error("alert sent during quiet hours")
+		return nil
+	})
+	held, _ = repo.DeferredAlerts()
+	if len(held) != 1 || len(held[subs[3].ID]) != 1 {
+		t.Errorf("after flush: %v, want only the quiet subscription's alert", held)
+	}
+}
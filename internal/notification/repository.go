@@ -1,9 +1,15 @@
 package notification
 
 import (
+	"errors"
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// ErrLimitReached is returned when a user already has MaxPerUser subscriptions.
+var ErrLimitReached = errors.New("subscription limit reached")
+
 type Repository struct {
 	DB *gorm.DB
 }
@@ -12,16 +18,119 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{DB: db}
 }
 
-func (r *Repository) UpsertNotification(n *Notification) error {
-	var existing Notification
-	err := r.DB.Where("user_id = ?", n.UserID).First(&existing).Error
-	if err == nil {
-		n.ID = existing.ID
-		return r.DB.Save(n).Error
-	} else if err == gorm.ErrRecordNotFound {
-		return r.DB.Create(n).Error
+// FindByName returns the user's subscription with the given name, ignoring
+// case like the uniqueness check of Create and Update.
+func (r *Repository) FindByName(userID uint, name string) (*Notification, error) {
+	var n Notification
+	if err := r.DB.Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, name).First(&n).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// CreateNotification adds a subscription, enforcing MaxPerUser.
+func (r *Repository) CreateNotification(n *Notification) error {
+	var count int64
+	if err := r.DB.Model(&Notification{}).Where("user_id = ?", n.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= MaxPerUser {
+		return ErrLimitReached
+	}
+	return r.DB.Create(n).Error
+}
+
+// UpdateNotification saves changes to an existing subscription.
+func (r *Repository) UpdateNotification(n *Notification) error {
+	return r.DB.Save(n).Error
+}
+
+// FindByUser returns one subscription owned by userID.
+func (r *Repository) FindByUser(userID, id uint) (*Notification, error) {
+	var n Notification
+	if err := r.DB.Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		return nil, err
 	}
-	return err
+	return &n, nil
+}
+
+// ListByUser returns the user's subscriptions in creation order.
+func (r *Repository) ListByUser(userID uint) ([]Notification, error) {
+	var notifications []Notification
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&notifications).Error
+	return notifications, err
+}
+
+// DeleteNotification removes a subscription owned by userID.
+func (r *Repository) DeleteNotification(userID, id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&Notification{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("notification_id = ?", id).Delete(&DeferredAlert{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("notification_id = ?", id).Delete(&AlertState{}).Error
+	})
+}
+
+// ListByType returns all subscriptions of one type (TypeAlert or TypeDigest).
+func (r *Repository) ListByType(typ string) ([]Notification, error) {
+	var notifications []Notification
+	err := r.DB.Where("type = ? AND unsubscribed_at IS NULL", typ).Find(&notifications).Error
+	return notifications, err
+}
+
+// FindByID returns a subscription regardless of its owner (for signed links).
+func (r *Repository) FindByID(id uint) (*Notification, error) {
+	var n Notification
+	if err := r.DB.First(&n, id).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// MarkEmailVerified confirms the address of a subscription.
+func (r *Repository) MarkEmailVerified(id uint) error {
+	return r.DB.Model(&Notification{}).Where("id = ?", id).UpdateColumn("email_verified_at", time.Now()).Error
+}
+
+// MarkVerificationSent records that the confirmation link for email went out at.
+func (r *Repository) MarkVerificationSent(id uint, email string, at time.Time) error {
+	return r.DB.Model(&Notification{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"verification_sent_to": email,
+		"verification_sent_at": at,
+	}).Error
+}
+
+// PendingVerifications returns active subscriptions with an unconfirmed
+// address that never got a verification mail (rows from before double opt-in).
+func (r *Repository) PendingVerifications() ([]Notification, error) {
+	var notifications []Notification
+	err := r.DB.Where("email <> '' AND email_verified_at IS NULL AND unsubscribed_at IS NULL AND verification_sent_to = ''").
+		Order("id").Find(&notifications).Error
+	return notifications, err
+}
+
+// Unsubscribe stops all messages of a subscription without deleting it.
+func (r *Repository) Unsubscribe(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Notification{}).Where("id = ? AND unsubscribed_at IS NULL", id).UpdateColumn("unsubscribed_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		// Sessiz saatlerde bekleyen uyarılar artık gönderilmeyecek
+		return tx.Where("notification_id = ?", id).Delete(&DeferredAlert{}).Error
+	})
+}
+
+// MarkDigestSent records when the digest of a subscription went out.
+func (r *Repository) MarkDigestSent(id uint, at time.Time) error {
+	return r.DB.Model(&Notification{}).Where("id = ?", id).UpdateColumn("last_digest_at", at).Error
 }
 
 func (r *Repository) GetAllNotifications() ([]Notification, error) {
@@ -29,3 +138,180 @@ func (r *Repository) GetAllNotifications() ([]Notification, error) {
 	err := r.DB.Find(&notifications).Error
 	return notifications, err
 }
+
+// AlertStates returns the stored alert state of the given subscriptions, keyed by subscription ID.
+func (r *Repository) AlertStates(ids []uint) (map[uint]AlertState, error) {
+	states := make(map[uint]AlertState, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+	var rows []AlertState
+	if err := r.DB.Where("notification_id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range rows {
+		states[s.NotificationID] = s
+	}
+	return states, nil
+}
+
+// SaveAlertState inserts or replaces the alert state of a subscription.
+func (r *Repository) SaveAlertState(state *AlertState) error {
+	return r.DB.Save(state).Error
+}
+
+// DeferAlert stores an alert until the subscription's quiet hours end.
+func (r *Repository) DeferAlert(d *DeferredAlert) error {
+	return r.DB.Create(d).Error
+}
+
+// DeferredAlerts returns all held-back alerts grouped by subscription, oldest first.
+func (r *Repository) DeferredAlerts() (map[uint][]DeferredAlert, error) {
+	var rows []DeferredAlert
+	if err := r.DB.Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint][]DeferredAlert)
+	for _, d := range rows {
+		out[d.NotificationID] = append(out[d.NotificationID], d)
+	}
+	return out, nil
+}
+
+// DeleteDeferredAlerts removes alerts once they were sent.
+func (r *Repository) DeleteDeferredAlerts(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.DB.Where("id IN ?", ids).Delete(&DeferredAlert{}).Error
+}
+
+// SavePushSubscription stores a browser for p.UserID. An endpoint that is
+// already known (same browser, possibly another account) is taken over and
+// its keys are refreshed. Beyond MaxPushPerUser the oldest browsers are dropped.
+func (r *Repository) SavePushSubscription(p *PushSubscription) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing PushSubscription
+		err := tx.Where("endpoint = ?", p.Endpoint).First(&existing).Error
+		switch {
+		case err == nil:
+			p.ID = existing.ID
+			p.CreatedAt = existing.CreatedAt
+			if err := tx.Save(p).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		var stale []uint
+		if err := tx.Model(&PushSubscription{}).Where("user_id = ?", p.UserID).
+			Order("updated_at DESC").Offset(MaxPushPerUser).Pluck("id", &stale).Error; err != nil {
+			return err
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+		return tx.Delete(&PushSubscription{}, stale).Error
+	})
+}
+
+// PushSubscriptions returns the browsers of a user.
+func (r *Repository) PushSubscriptions(userID uint) ([]PushSubscription, error) {
+	var subs []PushSubscription
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// DeletePushSubscription removes a browser of userID by endpoint.
+func (r *Repository) DeletePushSubscription(userID uint, endpoint string) error {
+	res := r.DB.Where("user_id = ? AND endpoint = ?", userID, endpoint).Delete(&PushSubscription{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeletePushEndpoint removes a browser the push service reported as gone.
+func (r *Repository) DeletePushEndpoint(endpoint string) error {
+	return r.DB.Where("endpoint = ?", endpoint).Delete(&PushSubscription{}).Error
+}
+
+// CreateTelegramCode stores a pending link code for userID, replacing the
+// user's earlier pending codes.
+func (r *Repository) CreateTelegramCode(userID uint, codeHash string, expiresAt time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND linked_at IS NULL", userID).Delete(&TelegramChat{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&TelegramChat{UserID: userID, CodeHash: codeHash, CodeExpiresAt: &expiresAt}).Error
+	})
+}
+
+// LinkTelegramChat redeems an unexpired link code for chatID. It returns
+// gorm.ErrRecordNotFound for unknown, used or expired codes.
+func (r *Repository) LinkTelegramChat(codeHash, chatID, title string, now time.Time) (*TelegramChat, error) {
+	var chat TelegramChat
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var pending TelegramChat
+		if err := tx.Where("code_hash = ? AND linked_at IS NULL AND code_expires_at > ?", codeHash, now).Take(&pending).Error; err != nil {
+			return err
+		}
+
+		// Aynı sohbet zaten bağlıysa yalnızca başlığı güncellenir
+		err := tx.Where("user_id = ? AND chat_id = ? AND linked_at IS NOT NULL", pending.UserID, chatID).Take(&chat).Error
+		switch {
+		case err == nil:
+			chat.Title = title
+			if err := tx.Save(&chat).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&pending).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			chat = pending
+			chat.ChatID, chat.Title, chat.LinkedAt = chatID, title, &now
+			chat.CodeHash, chat.CodeExpiresAt = "", nil
+			return tx.Save(&chat).Error
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// TelegramChats returns the linked chats of a user.
+func (r *Repository) TelegramChats(userID uint) ([]TelegramChat, error) {
+	var chats []TelegramChat
+	err := r.DB.Where("user_id = ? AND linked_at IS NOT NULL", userID).Order("id").Find(&chats).Error
+	return chats, err
+}
+
+// TelegramLinked reports whether userID has linked chatID.
+func (r *Repository) TelegramLinked(userID uint, chatID string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&TelegramChat{}).Where("user_id = ? AND chat_id = ? AND linked_at IS NOT NULL", userID, chatID).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteTelegramChat unlinks a chat of userID. Subscriptions that still name
+// it stop receiving telegram alerts.
+func (r *Repository) DeleteTelegramChat(userID, id uint) error {
+	res := r.DB.Where("user_id = ? AND id = ? AND linked_at IS NOT NULL", userID, id).Delete(&TelegramChat{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -2,60 +2,340 @@ package notification
 
 import (
 	"log"
+	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"nasa-app/internal/airquality"
 	"nasa-app/internal/mlclient"
 )
 
+// ScanOptions controls how one scheduler pass is spread out.
+type ScanOptions struct {
+	// CellDegrees is the grid cell size: subscriptions in the same cell share
+	// one metrics fetch and one prediction, taken at the cell centre. With 0
+	// only subscriptions at exactly the same coordinates are merged.
+	CellDegrees float64
+	// Workers bounds the concurrent metric fetches and evaluations.
+	Workers int
+}
+
+// cellKey identifies a grid cell (or, without a grid, an exact coordinate).
+type cellKey struct{ lat, lon float64 }
+
+func (o ScanOptions) cell(lat, lon float64) cellKey {
+	if o.CellDegrees <= 0 {
+		return cellKey{lat, lon}
+	}
+	// Hücre merkezi; aynı hücredeki abonelikler tek ölçümü paylaşır
+	return cellKey{
+		lat: math.Round(lat/o.CellDegrees) * o.CellDegrees,
+		lon: math.Round(lon/o.CellDegrees) * o.CellDegrees,
+	}
+}
+
+// locationGroup is the subscriptions of one cell.
+type locationGroup struct {
+	at      Notification // cell centre, used for the fetch and the prediction
+	members []Notification
+	metrics airquality.Metrics
+	err     error
+}
+
+// StartScheduler periodically fetches metrics for every location, checks each
+// subscription's threshold and calls notifyFunc when policy allows an alert.
+// Subscriptions are grouped into grid cells (see ScanOptions) so metrics and
+// ML predictions are requested once per cell; predictions go out in one batch.
+// When they fail the reading is still checked with risk level "unknown", so
+// AQI and pollutant thresholds keep working. Alerts that fall in a
+// subscription's quiet hours are stored and sent together once the window ends.
 func StartScheduler(
 	repo *Repository,
 	interval time.Duration,
+	policy AlertPolicy,
+	opts ScanOptions,
 	metricsFunc func(Notification) (airquality.Metrics, error),
-	predictFunc func(Notification, airquality.Metrics) (mlclient.PredictionResponse, error),
-	notifyFunc func(Notification, airquality.Metrics, mlclient.PredictionResponse) error,
+	predictFunc func([]Notification, []airquality.Metrics) ([]mlclient.BatchResult, error),
+	notifyFunc func(Alert) error,
 ) {
 	if interval <= 0 {
 		interval = 30 * time.Minute
 	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
 
 	go func() {
 		for {
-			notifs, err := repo.GetAllNotifications()
+			start := time.Now()
+			notifs, err := repo.ListByType(TypeAlert)
 			if err != nil {
 				log.Println("Scheduler DB error:", err)
 				time.Sleep(time.Minute)
 				continue
 			}
 
-			for _, n := range notifs {
-				metrics, err := metricsFunc(n)
-				if err != nil {
-					log.Println("Metrics fetch error:", err)
-					continue
-				}
-
-				prediction, err := predictFunc(n, metrics)
-				if err != nil {
-					log.Println("Prediction error:", err)
-					continue
-				}
-
-				riskLevel := strings.ToLower(strings.TrimSpace(prediction.RiskLevel))
-				switch riskLevel {
-				case "poor", "hazardous":
-					if err := notifyFunc(n, metrics, prediction); err != nil {
-						log.Println("Notification error:", err)
-					}
-				case "good", "moderate":
-					log.Printf("Risk level %s does not require alert for user %d", riskLevel, n.UserID)
-				default:
-					log.Printf("Unknown risk level '%s' for user %d, skipping notification", riskLevel, n.UserID)
-				}
-			}
+			scan(repo, policy, opts, notifs, metricsFunc, predictFunc, notifyFunc)
+			flushDeferred(repo, notifs, notifyFunc)
 
-			time.Sleep(interval)
+			// Aralık tur başlangıçları arasındadır; uzun süren tur hemen tekrar başlar
+			took := time.Since(start)
+			if took > interval {
+				log.Printf("Scheduler pass took %s, longer than the %s interval", took.Round(time.Second), interval)
+			}
+			time.Sleep(interval - took)
 		}
 	}()
 }
+
+// scan runs one pass over notifs.
+func scan(
+	repo *Repository,
+	policy AlertPolicy,
+	opts ScanOptions,
+	notifs []Notification,
+	metricsFunc func(Notification) (airquality.Metrics, error),
+	predictFunc func([]Notification, []airquality.Metrics) ([]mlclient.BatchResult, error),
+	notifyFunc func(Alert) error,
+) {
+	groups := groupByCell(opts, notifs)
+
+	// Ölçümler hücre başına bir kez, sınırlı sayıda eşzamanlı istekle alınır
+	parallel(len(groups), opts.Workers, func(i int) {
+		g := groups[i]
+		g.metrics, g.err = metricsFunc(g.at)
+	})
+
+	fetched := make([]*locationGroup, 0, len(groups))
+	for _, g := range groups {
+		if g.err != nil {
+			log.Printf("Metrics fetch error for %d subscription(s) at %.4f,%.4f: %v", len(g.members), g.at.Latitude, g.at.Longitude, g.err)
+			continue
+		}
+		fetched = append(fetched, g)
+	}
+	if len(fetched) == 0 {
+		return
+	}
+
+	locs := make([]Notification, len(fetched))
+	metrics := make([]airquality.Metrics, len(fetched))
+	for i, g := range fetched {
+		locs[i], metrics[i] = g.at, g.metrics
+	}
+	results, err := predictFunc(locs, metrics)
+	if err != nil {
+		log.Println("Prediction error:", err)
+	}
+
+	type job struct {
+		n         Notification
+		metrics   airquality.Metrics
+		riskLevel string
+		predicted mlclient.PredictionResponse
+	}
+	var jobs []job
+	var ids []uint
+	for i, g := range fetched {
+		riskLevel := "unknown"
+		var predicted mlclient.PredictionResponse
+		if i < len(results) {
+			if results[i].Err != nil {
+				log.Printf("Prediction error at %.4f,%.4f: %v", g.at.Latitude, g.at.Longitude, results[i].Err)
+			} else if results[i].Prediction.RiskLevel != "" {
+				riskLevel, predicted = results[i].Prediction.RiskLevel, results[i].Prediction
+			}
+		}
+		for _, n := range g.members {
+			jobs = append(jobs, job{n: n, metrics: g.metrics, riskLevel: riskLevel, predicted: predicted})
+			ids = append(ids, n.ID)
+		}
+	}
+
+	states, err := repo.AlertStates(ids)
+	if err != nil {
+		// Durum okunamazsa uyarı göndermeyelim; aksi halde tekrar eden e-postalar gider
+		log.Println("Alert state load error:", err)
+		return
+	}
+
+	// Abonelik başına satır yerine tur sonunda sayılar loglanır
+	var counts [outcomeCount]atomic.Int64
+	parallel(len(jobs), opts.Workers, func(i int) {
+		j := jobs[i]
+		counts[evaluate(repo, policy, j.n, states[j.n.ID], j.metrics, j.riskLevel, j.predicted, notifyFunc)].Add(1)
+	})
+	log.Printf("Scheduler pass: %d subscription(s) in %d location(s): %d notified, %d deferred, %d suppressed, %d below threshold, %d failed",
+		len(jobs), len(fetched), counts[outcomeNotified].Load(), counts[outcomeDeferred].Load(),
+		counts[outcomeSuppressed].Load(), counts[outcomeBelow].Load(), counts[outcomeFailed].Load())
+}
+
+// outcome is what evaluate did with one subscription.
+type outcome int
+
+const (
+	outcomeNotified   outcome = iota // alert or all-clear handed to notifyFunc
+	outcomeDeferred                  // held back for quiet hours
+	outcomeSuppressed                // above threshold, policy allowed no alert
+	outcomeBelow                     // below threshold
+	outcomeFailed
+	outcomeCount
+)
+
+// groupByCell groups subscriptions by grid cell, in order of first appearance.
+func groupByCell(opts ScanOptions, notifs []Notification) []*locationGroup {
+	byCell := make(map[cellKey]*locationGroup)
+	var groups []*locationGroup
+	for _, n := range notifs {
+		key := opts.cell(n.Latitude, n.Longitude)
+		g, ok := byCell[key]
+		if !ok {
+			g = &locationGroup{at: Notification{Latitude: key.lat, Longitude: key.lon}}
+			byCell[key] = g
+			groups = append(groups, g)
+		}
+		g.members = append(g.members, n)
+	}
+	return groups
+}
+
+// parallel calls fn(0..n-1) on at most workers goroutines and waits for all.
+func parallel(n, workers int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+func evaluate(
+	repo *Repository,
+	policy AlertPolicy,
+	n Notification,
+	state AlertState,
+	metrics airquality.Metrics,
+	riskLevel string,
+	predicted mlclient.PredictionResponse,
+	notifyFunc func(Alert) error,
+) outcome {
+	riskLevel = strings.ToLower(strings.TrimSpace(riskLevel))
+	now := time.Now()
+	ev := n.Evaluate(metrics, riskLevel)
+	action, next, episode := policy.Decide(n, state, ev, metrics, now)
+
+	result := outcomeBelow
+	switch {
+	case action != ActionNone:
+		alert := Alert{Kind: KindAlert, At: now, Subscription: n, Metrics: metrics, RiskLevel: riskLevel, Prediction: predicted, Evaluation: ev}
+		if action == ActionAllClear {
+			alert.Kind, alert.Episode = KindAllClear, episode
+		}
+		if n.InQuietHours(now) && !(n.QuietBreakthrough && alert.Hazardous()) {
+			// Sessiz saatlerde gönderme; pencere bitince toplu gönderilir
+			if err := repo.DeferAlert(&DeferredAlert{NotificationID: n.ID, Alert: alert}); err != nil {
+				log.Printf("Deferring alert for subscription %d failed: %v", n.ID, err)
+				return outcomeFailed
+			}
+			result = outcomeDeferred
+			break
+		}
+		if err := notifyFunc(alert); err != nil {
+			// Durum kaydedilmez; bir sonraki turda tekrar denenir
+			log.Printf("Notification error for subscription %d: %v", n.ID, err)
+			return outcomeFailed
+		}
+		log.Printf("Subscription %d (user %d) %s: %s", n.ID, n.UserID, alert.Kind, ev.Describe(n, n.EmailLocale()))
+		result = outcomeNotified
+	case ev.Exceeded:
+		result = outcomeSuppressed
+	}
+
+	if err := repo.SaveAlertState(&next); err != nil {
+		log.Printf("Alert state save error for subscription %d: %v", n.ID, err)
+		return outcomeFailed
+	}
+	return result
+}
+
+// flushDeferred sends the alerts held back for subscriptions whose quiet hours
+// have ended, one message per subscription. notifs are all active alert
+// subscriptions; alerts held for any other subscription are dropped.
+func flushDeferred(repo *Repository, notifs []Notification, notifyFunc func(Alert) error) {
+	deferred, err := repo.DeferredAlerts()
+	if err != nil {
+		log.Println("Deferred alert load error:", err)
+		return
+	}
+	if len(deferred) == 0 {
+		return
+	}
+
+	// Silinmiş, aboneliği kaldırılmış ya da özete çevrilmiş abonelikler
+	active := make(map[uint]bool, len(notifs))
+	for _, n := range notifs {
+		active[n.ID] = true
+	}
+	var stale []uint
+	for id, held := range deferred {
+		if active[id] {
+			continue
+		}
+		for _, d := range held {
+			stale = append(stale, d.ID)
+		}
+	}
+	if len(stale) > 0 {
+		if err := repo.DeleteDeferredAlerts(stale); err != nil {
+			log.Println("Stale deferred alert cleanup error:", err)
+		} else {
+			log.Printf("Dropped %d deferred alert(s) of removed subscriptions", len(stale))
+		}
+	}
+
+	now := time.Now()
+	for _, n := range notifs {
+		held := deferred[n.ID]
+		if len(held) == 0 || n.InQuietHours(now) {
+			continue
+		}
+
+		ids := make([]uint, len(held))
+		batch := make([]Alert, len(held))
+		for i, d := range held {
+			ids[i], batch[i] = d.ID, d.Alert
+		}
+		latest := batch[len(batch)-1]
+		alert := Alert{
+			Kind:         KindDeferred,
+			At:           now,
+			Subscription: n,
+			Metrics:      latest.Metrics,
+			RiskLevel:    latest.RiskLevel,
+			Evaluation:   latest.Evaluation,
+			Batch:        batch,
+		}
+		if err := notifyFunc(alert); err != nil {
+			log.Println("Notification error:", err)
+			continue
+		}
+		if err := repo.DeleteDeferredAlerts(ids); err != nil {
+			log.Printf("Deferred alert cleanup failed for subscription %d: %v", n.ID, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nasa-app/internal/airquality"
+	"nasa-app/internal/mlclient"
+)
+
+type recordingSMS struct{ sent []string }
+
+func (p *recordingSMS) SendSMS(to, body string) error {
+	p.sent = append(p.sent, to+" "+body)
+	return nil
+}
+
+// A hazardous alert held back by quiet hours must still reach the phone when
+// the window ends; lesser alerts stay off SMS.
+func TestQuietHoursDeferredSMS(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics airquality.Metrics
+		wantSMS int
+	}{
+		{"hazardous", airquality.Metrics{PM25: 300}, 1},
+		{"unhealthy", airquality.Metrics{PM25: 60}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			now := time.Now().UTC()
+			n := Notification{
+				UserID: 7, Name: "Home", Threshold: 100, Channels: []string{ChannelSMS},
+				QuietStart: now.Add(-time.Hour).Format(quietClock),
+				QuietEnd:   now.Add(time.Hour).Format(quietClock),
+			}
+			if err := repo.DB.Create(&n).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			sms := &recordingSMS{}
+			sender := NewSMSSender(sms, func(uint) (string, error) { return "+905551234567", nil })
+			var notified []Alert
+			notify := func(a Alert) error {
+				notified = append(notified, a)
+				return sender.SendAlert(a)
+			}
+
+			got := evaluate(repo, AlertPolicy{Cooldown: time.Hour}, n, AlertState{}, tt.metrics, "unknown", mlclient.PredictionResponse{}, notify)
+			if got != outcomeDeferred || len(notified) != 0 {
+				t.Fatalf("in quiet hours: outcome %d, %d notification(s); want deferred and none", got, len(notified))
+			}
+
+			// Pencere bitti
+			n.QuietStart, n.QuietEnd = now.Add(2*time.Hour).Format(quietClock), now.Add(3*time.Hour).Format(quietClock)
+			flushDeferred(repo, []Notification{n}, notify)
+
+			if len(notified) != 1 || notified[0].Kind != KindDeferred {
+				t.Fatalf("after quiet hours: %d notification(s), want one deferred batch", len(notified))
+			}
+			if len(sms.sent) != tt.wantSMS {
+				t.Errorf("sent %d SMS, want %d", len(sms.sent), tt.wantSMS)
+			}
+			if tt.wantSMS > 0 && !strings.HasPrefix(sms.sent[0], "+905551234567 ") {
+				t.Errorf("SMS went to %q", sms.sent[0])
+			}
+			if held, _ := repo.DeferredAlerts(); len(held) != 0 {
+				t.Errorf("%d deferred alert(s) left after the flush", len(held))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSProvider sends one text message to an E.164 number.
+type SMSProvider interface {
+	SendSMS(to, body string) error
+}
+
+var (
+	_ SMSProvider = (*HTTPSMSProvider)(nil)
+	_ SMSProvider = (*LogSMSProvider)(nil)
+)
+
+// maxSMSRunes keeps a message within a few segments (Turkish text is sent as UCS-2).
+const maxSMSRunes = 300
+
+// HTTPSMSProvider talks to a Twilio-compatible form API:
+// POST {baseURL}/2010-04-01/Accounts/{account}/Messages.json with To, From
+// and Body, authenticated with HTTP basic auth (account, token).
+type HTTPSMSProvider struct {
+	client   *http.Client
+	endpoint string
+	account  string
+	token    string
+	from     string
+}
+
+// NewHTTPSMSProvider creates a provider. If client is nil, a client with a 10
+// second timeout is used; baseURL defaults to the Twilio API.
+func NewHTTPSMSProvider(client *http.Client, baseURL, account, token, from string) (*HTTPSMSProvider, error) {
+	if account == "" || token == "" || from == "" {
+		return nil, errors.New("SMS account, token and sender are required")
+	}
+	if baseURL == "" {
+		baseURL = "https://api.twilio.com"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSMSProvider{
+		client:   client,
+		endpoint: strings.TrimRight(baseURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(account) + "/Messages.json",
+		account:  account,
+		token:    token,
+		from:     from,
+	}, nil
+}
+
+func (p *HTTPSMSProvider) SendSMS(to, body string) error {
+	form := url.Values{"To": {to}, "From": {p.from}, "Body": {body}}
+	req, err := http.NewRequest(http.MethodPost, p.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("create sms request: %w", err)
+	}
+	req.SetBasicAuth(p.account, p.token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("sms request failed: status %d, response: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// LogSMSProvider is for development: messages are appended to a file, or
+// written to the log when no file is given. Nothing is sent.
+type LogSMSProvider struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewLogSMSProvider(path string) *LogSMSProvider {
+	return &LogSMSProvider{path: path}
+}
+
+func (p *LogSMSProvider) SendSMS(to, body string) error {
+	if p.path == "" {
+		log.Printf("SMS to %s: %s", to, body)
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open sms log: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), to, strings.ReplaceAll(body, "\n", " "))
+	return err
+}
+
+// SMSSender texts hazardous alerts to the subscription owner's phone. SMS is
+// the channel for people who don't read email, so it stays quiet otherwise.
+type SMSSender struct {
+	provider SMSProvider
+	phone    func(userID uint) (string, error)
+}
+
+// NewSMSSender creates a sender; phone looks up the owner's E.164 number.
+func NewSMSSender(provider SMSProvider, phone func(userID uint) (string, error)) *SMSSender {
+	return &SMSSender{provider: provider, phone: phone}
+}
+
+// SendAlert texts alert if it is hazardous and skips it otherwise.
+func (s *SMSSender) SendAlert(alert Alert) error {
+	if !alert.Hazardous() {
+		return nil
+	}
+	n := alert.Subscription
+	to, err := s.phone(n.UserID)
+	if err != nil {
+		return fmt.Errorf("phone lookup for user %d: %w", n.UserID, err)
+	}
+	if to == "" {
+		log.Printf("subscription %d uses sms but user %d has no phone number", n.ID, n.UserID)
+		return nil
+	}
+
+	title, body, err := renderShort(n.EmailLocale(), alert.Kind, alertData(alert))
+	if err != nil {
+		return err
+	}
+	text := title + "\n" + body
+	if r := []rune(text); len(r) > maxSMSRunes {
+		text = string(r[:maxSMSRunes-1]) + "…"
+	}
+	return s.provider.SendSMS(to, text)
+}
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelegramChat is a chat a user linked to their account by sending the bot
+// "/start <code>". Only linked chats are accepted as a subscription's
+// telegram_chat_id, so nobody can point alerts at someone else's chat. Until
+// the bot sees the code the row is pending: it holds the code and no chat.
+type TelegramChat struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UserID        uint       `gorm:"index;not null" json:"-"`
+	ChatID        string     `gorm:"size:64;index" json:"chat_id"`
+	Title         string     `gorm:"size:128" json:"title"` // group title or @username
+	LinkedAt      *time.Time `json:"linked_at"`
+	CodeHash      string     `gorm:"size:64;index" json:"-"`
+	CodeExpiresAt *time.Time `json:"-"`
+}
+
+func (TelegramChat) TableName() string { return "notification_telegram_chats" }
+
+// telegramCodeTTL is how long a link code may take to reach the bot.
+const telegramCodeTTL = 15 * time.Minute
+
+// TelegramWebhookSecret is the format Telegram accepts for secret_token.
+var TelegramWebhookSecret = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// newTelegramCode returns a one-time link code (a valid /start deep-link
+// parameter) and the hash that is stored in its place.
+func newTelegramCode() (code, hash string) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	code = base64.RawURLEncoding.EncodeToString(b)
+	return code, telegramCodeHash(code)
+}
+
+func telegramCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// telegramUpdate is the part of a Bot API update the link handshake reads.
+type telegramUpdate struct {
+	Message *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID       int64  `json:"id"`
+			Title    string `json:"title"`
+			Username string `json:"username"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// startCode returns the code of a "/start <code>" (or "/start@bot <code>") message.
+func startCode(text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || (fields[0] != "/start" && !strings.HasPrefix(fields[0], "/start@")) {
+		return "", false
+	}
+	if len(fields) != 2 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+func (u telegramUpdate) chat() (id, title string) {
+	c := u.Message.Chat
+	title = c.Title
+	if title == "" && c.Username != "" {
+		title = "@" + c.Username
+	}
+	return strconv.FormatInt(c.ID, 10), title
+}
@@ -0,0 +1,141 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTelegramLink(t *testing.T) {
+	repo := newTestRepo(t)
+	replies := map[string]string{}
+	h := &Handler{Repo: repo}
+	h.UseTelegram("@clean_breathing_bot", "hook-secret", func(chatID, text string) error {
+		replies[chatID] = text
+		return nil
+	})
+	app := fiber.New()
+	app.Post("/telegram/webhook", h.TelegramWebhook)
+	api := app.Group("", func(c *fiber.Ctx) error {
+		c.Locals("user_id", uint(1))
+		return c.Next()
+	})
+	api.Post("/notifications/telegram/link", h.LinkTelegram)
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/notifications/telegram/link", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var link struct {
+		Code string `json:"code"`
+		URL  string `json:"url"`
+	}
+	json.NewDecoder(resp.Body).Decode(&link)
+	if resp.StatusCode != fiber.StatusCreated || link.Code == "" {
+		t.Fatalf("link: status %d, code %q", resp.StatusCode, link.Code)
+	}
+	if link.URL != "https://t.me/clean_breathing_bot?start="+link.Code {
+		t.Errorf("link url = %q", link.URL)
+	}
+
+	update := func(secret string, chatID int64, text string) int {
+		t.Helper()
+		body := fmt.Sprintf(`{"update_id":1,"message":{"text":%q,"chat":{"id":%d,"type":"private","username":"ayse"}}}`, text, chatID)
+		req := httptest.NewRequest("POST", "/telegram/webhook", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	// Sahte güncelleme: gizli anahtar yanlış
+	if got := update("wrong", 555, "/start "+link.Code); got != fiber.StatusUnauthorized {
+		t.Errorf("wrong secret: status %d, want %d", got, fiber.StatusUnauthorized)
+	}
+	if ok, _ := repo.TelegramLinked(1, "555"); ok {
+		t.Fatal("chat linked through an unauthenticated update")
+	}
+
+	if got := update("hook-secret", 555, "/start@clean_breathing_bot "+link.Code); got != fiber.StatusOK {
+		t.Fatalf("start: status %d", got)
+	}
+	if ok, err := repo.TelegramLinked(1, "555"); !ok || err != nil {
+		t.Fatalf("chat not linked after /start: %v", err)
+	}
+	if !strings.Contains(replies["555"], "now linked") {
+		t.Errorf("reply = %q", replies["555"])
+	}
+
+	// Kod tek kullanımlık
+	if got := update("hook-secret", 777, "/start "+link.Code); got != fiber.StatusOK {
+		t.Fatalf("reused code: status %d", got)
+	}
+	if ok, _ := repo.TelegramLinked(1, "777"); ok {
+		t.Error("a used code linked a second chat")
+	}
+	if !strings.Contains(replies["777"], "invalid or expired") {
+		t.Errorf("reply to a reused code = %q", replies["777"])
+	}
+
+	chats, err := repo.TelegramChats(1)
+	if err != nil || len(chats) != 1 || chats[0].ChatID != "555" || chats[0].Title != "@ayse" {
+		t.Errorf("TelegramChats = %+v, %v", chats, err)
+	}
+}
+
+func TestTelegramCodeExpired(t *testing.T) {
+	repo := newTestRepo(t)
+	code, hash := newTelegramCode()
+	if err := repo.CreateTelegramCode(1, hash, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.LinkTelegramChat(telegramCodeHash(code), "555", "", time.Now()); err == nil {
+		t.Error("an expired code linked a chat")
+	}
+
+	// Yeni kod öncekini geçersiz kılar
+	first, firstHash := newTelegramCode()
+	second, secondHash := newTelegramCode()
+	repo.CreateTelegramCode(1, firstHash, time.Now().Add(telegramCodeTTL))
+	repo.CreateTelegramCode(1, secondHash, time.Now().Add(telegramCodeTTL))
+	if _, err := repo.LinkTelegramChat(telegramCodeHash(first), "555", "", time.Now()); err == nil {
+		t.Error("a replaced code linked a chat")
+	}
+	if _, err := repo.LinkTelegramChat(telegramCodeHash(second), "555", "", time.Now()); err != nil {
+		t.Errorf("latest code: %v", err)
+	}
+}
+
+func TestCheckChannelsTelegram(t *testing.T) {
+	repo := newTestRepo(t)
+	h := &Handler{Repo: repo}
+	req := &subscribeRequest{Channels: []string{ChannelTelegram}, TelegramChatID: "555"}
+	if msg := h.checkChannels(1, req); msg == "" {
+		t.Error("telegram accepted without a bot")
+	}
+
+	h.UseTelegram("", "hook-secret", nil)
+	if msg := h.checkChannels(1, req); msg == "" {
+		t.Error("an unlinked chat was accepted")
+	}
+
+	code, hash := newTelegramCode()
+	repo.CreateTelegramCode(1, hash, time.Now().Add(telegramCodeTTL))
+	if _, err := repo.LinkTelegramChat(telegramCodeHash(code), "555", "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if msg := h.checkChannels(1, req); msg != "" {
+		t.Errorf("linked chat refused: %s", msg)
+	}
+	if msg := h.checkChannels(2, req); msg == "" {
+		t.Error("another user's linked chat was accepted")
+	}
+}
@@ -0,0 +1,327 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"nasa-app/internal/airquality"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Locales are the languages emails can be sent in; the first one is the default.
+var Locales = []string{"tr", "en"}
+
+// DefaultLocale is used for subscriptions without a (known) locale.
+const DefaultLocale = "tr"
+
+var templateFuncs = map[string]any{
+	"join":     strings.Join,
+	"standard": standardLabel,
+}
+
+// standardLabel is the display name of an AQI standard.
+func standardLabel(standard string) string {
+	switch standard {
+	case airquality.StandardUSEPA:
+		return "US EPA"
+	case airquality.StandardEU:
+		return "EU"
+	}
+	return strings.ToUpper(standard)
+}
+
+// emailTemplates holds the parsed templates of one locale. Every message
+// kind has a "<kind>_subject" and "<kind>" text template and a "<kind>" HTML
+// template; alert kinds also have a "<kind>_short" text template.
+type emailTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = func() map[string]emailTemplates {
+	out := make(map[string]emailTemplates, len(Locales))
+	for _, locale := range Locales {
+		out[locale] = emailTemplates{
+			text: texttemplate.Must(texttemplate.New(locale).Funcs(templateFuncs).ParseFS(templateFS, "templates/"+locale+".txt.tmpl")),
+			html: htmltemplate.Must(htmltemplate.New(locale).Funcs(templateFuncs).ParseFS(templateFS, "templates/"+locale+".html.tmpl")),
+		}
+	}
+	return out
+}()
+
+// renderEmail executes the templates of kind in locale.
+func renderEmail(locale, kind string, data emailData) (subject, text, html string, err error) {
+	t, ok := templates[locale]
+	if !ok {
+		t = templates[DefaultLocale]
+	}
+
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, kind+"_subject", data); err != nil {
+		return "", "", "", fmt.Errorf("render %s subject: %w", kind, err)
+	}
+	subject = buf.String()
+
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, kind, data); err != nil {
+		return "", "", "", fmt.Errorf("render %s text: %w", kind, err)
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, kind, data); err != nil {
+		return "", "", "", fmt.Errorf("render %s html: %w", kind, err)
+	}
+	return subject, text, buf.String(), nil
+}
+
+// renderShort executes the "<kind>_subject" and "<kind>_short" text templates
+// of kind in locale: a title and a one or two sentence body for channels
+// that cannot carry a whole email (push, SMS, chat).
+func renderShort(locale, kind string, data emailData) (title, body string, err error) {
+	t, ok := templates[locale]
+	if !ok {
+		t = templates[DefaultLocale]
+	}
+
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, kind+"_subject", data); err != nil {
+		return "", "", fmt.Errorf("render %s subject: %w", kind, err)
+	}
+	title = buf.String()
+
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, kind+"_short", data); err != nil {
+		return "", "", fmt.Errorf("render %s short text: %w", kind, err)
+	}
+	return title, strings.TrimSpace(buf.String()), nil
+}
+
+// renderPage executes a standalone HTML page (unsubscribe, confirmation) in locale.
+func renderPage(locale, name string, data any) (string, error) {
+	t, ok := templates[locale]
+	if !ok {
+		t = templates[DefaultLocale]
+	}
+	var buf bytes.Buffer
+	if err := t.html.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("render %s page: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// pageData is what the standalone pages see.
+type pageData struct {
+	Place string
+	Token string
+	Email string
+}
+
+// emailData is what the templates see. Numbers and times are pre-formatted
+// in the subscription's timezone so templates stay free of logic.
+type emailData struct {
+	Place     string
+	RiskLevel string
+	Severity  string // good, moderate, poor or hazardous; selects the advice
+	AQI       airquality.AQIResult
+	Reason    reasonData
+	Metrics   []metricRow
+	Drivers   []string
+	Episode   *episodeData
+	Batch     []batchRow
+	Digest    *digestData
+
+	UnsubscribeURL string
+	VerifyURL      string
+}
+
+type reasonData struct {
+	Kind      string
+	Value     string
+	Limit     int
+	Standard  string
+	Pollutant string // display label
+	Risk      string
+	MinRisk   string
+}
+
+type metricRow struct {
+	Key   string // feature name; templates translate temperature and humidity
+	Label string
+	Value string
+	Unit  string
+}
+
+type episodeData struct {
+	Hours, Minutes int
+	PeakAQI        int
+	Peaks          []metricRow
+}
+
+type batchRow struct {
+	Time      string
+	Kind      string
+	RiskLevel string
+	Reason    reasonData
+}
+
+type digestData struct {
+	Frequency  string
+	Stats      []statsRow
+	Timeline   []timelineRow
+	BestWindow *windowRow
+}
+
+type statsRow struct {
+	Label, Min, Mean, Max string
+}
+
+type timelineRow struct {
+	Time      string
+	RiskLevel string
+	AQI       int
+}
+
+type windowRow struct {
+	Start, End string
+	RiskLevel  string
+	AQI        int
+}
+
+// EmailLocale returns the email language of the subscription.
+func (n Notification) EmailLocale() string {
+	if slices.Contains(Locales, n.Locale) {
+		return n.Locale
+	}
+	return DefaultLocale
+}
+
+func placeName(n Notification) string {
+	if n.Name == "" {
+		return DefaultName
+	}
+	return n.Name
+}
+
+func normalRisk(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if riskRank(level) < 0 {
+		return "unknown"
+	}
+	return level
+}
+
+// severity picks the advice: the ML risk level when known, otherwise the AQI category.
+func severity(riskLevel string, ev Evaluation) string {
+	if rank := riskRank(riskLevel); rank >= 0 {
+		return RiskLevels[rank]
+	}
+	switch level := aqiLevel(ev.AQI); {
+	case level <= 0:
+		return "good"
+	case level == 1:
+		return "moderate"
+	case level <= 3:
+		return "poor"
+	default:
+		return "hazardous"
+	}
+}
+
+func newReasonData(n Notification, ev Evaluation, riskLevel string) reasonData {
+	r := n.Rule()
+	rd := reasonData{Kind: ev.Kind, Limit: r.Threshold, Standard: r.AQIStandard, Risk: normalRisk(riskLevel), MinRisk: r.MinRisk}
+	switch ev.Kind {
+	case ThresholdAQI:
+		rd.Value = fmt.Sprintf("%d", int(ev.Value))
+	case ThresholdPollutant:
+		rd.Value = fmt.Sprintf("%.1f", ev.Value)
+		p, _ := pollutant(r.Pollutant)
+		rd.Pollutant = p.Label
+	}
+	return rd
+}
+
+func metricRows(m airquality.Metrics) []metricRow {
+	rows := make([]metricRow, 0, len(airquality.Pollutants)+2)
+	values := m.FeatureMap()
+	for _, p := range airquality.Pollutants {
+		rows = append(rows, metricRow{Key: p.Feature, Label: p.Label, Value: fmt.Sprintf("%.1f", values[p.Feature]), Unit: "µg/m³"})
+	}
+	return append(rows,
+		metricRow{Key: "temperature", Value: fmt.Sprintf("%.1f", m.Temperature), Unit: "°C"},
+		metricRow{Key: "humidity", Value: fmt.Sprintf("%.0f", m.Humidity), Unit: "%"},
+	)
+}
+
+func alertData(alert Alert) emailData {
+	n := alert.Subscription
+	loc := n.Location()
+	d := emailData{
+		Place:     placeName(n),
+		RiskLevel: normalRisk(alert.RiskLevel),
+		Severity:  severity(alert.RiskLevel, alert.Evaluation),
+		AQI:       alert.Evaluation.AQI,
+		Reason:    newReasonData(n, alert.Evaluation, alert.RiskLevel),
+		Metrics:   metricRows(alert.Metrics),
+		Drivers:   alert.Drivers,
+	}
+
+	if e := alert.Episode; e != nil {
+		dur := e.Duration().Round(time.Minute)
+		ep := &episodeData{Hours: int(dur.Hours()), Minutes: int(dur.Minutes()) % 60, PeakAQI: e.PeakAQI}
+		for _, p := range airquality.Pollutants {
+			if v, ok := e.Peaks[p.Feature]; ok {
+				ep.Peaks = append(ep.Peaks, metricRow{Label: p.Label, Value: fmt.Sprintf("%.1f", v), Unit: "µg/m³"})
+			}
+		}
+		d.Episode = ep
+	}
+
+	for _, a := range alert.Batch {
+		d.Batch = append(d.Batch, batchRow{
+			Time:      a.At.In(loc).Format("02.01 15:04"),
+			Kind:      a.Kind,
+			RiskLevel: normalRisk(a.RiskLevel),
+			Reason:    newReasonData(n, a.Evaluation, a.RiskLevel),
+		})
+	}
+	return d
+}
+
+func digestEmailData(dg Digest) emailData {
+	n := dg.Subscription
+	loc := n.Location()
+	d := emailData{Place: placeName(n), Digest: &digestData{Frequency: dg.Frequency}}
+
+	for _, st := range dg.Stats {
+		d.Digest.Stats = append(d.Digest.Stats, statsRow{
+			Label: st.Label,
+			Min:   fmt.Sprintf("%.1f", st.Min),
+			Mean:  fmt.Sprintf("%.1f", st.Mean),
+			Max:   fmt.Sprintf("%.1f", st.Max),
+		})
+	}
+	r := n.Rule()
+	for _, p := range dg.Timeline {
+		aqi, _ := airquality.AQI(r.AQIStandard, p.Metrics)
+		d.Digest.Timeline = append(d.Digest.Timeline, timelineRow{Time: p.Time.In(loc).Format("15:04"), RiskLevel: normalRisk(p.RiskLevel), AQI: aqi.Value})
+	}
+	if w := dg.BestWindow; w != nil {
+		d.Digest.BestWindow = &windowRow{
+			Start:     w.Start.In(loc).Format("15:04"),
+			End:       w.End.In(loc).Format("15:04"),
+			RiskLevel: normalRisk(w.RiskLevel),
+			AQI:       w.AQI,
+		}
+	}
+	return d
+}
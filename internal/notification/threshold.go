@@ -0,0 +1,176 @@
+package notification
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"nasa-app/internal/airquality"
+)
+
+// Threshold kinds: what Notification.Threshold is compared against.
+const (
+	ThresholdAQI       = "aqi"       // AQI value under AQIStandard
+	ThresholdPollutant = "pollutant" // concentration of Pollutant in µg/m³
+	ThresholdRisk      = "risk"      // ML risk category at or above MinRisk
+)
+
+// ThresholdKinds lists the supported threshold kinds.
+var ThresholdKinds = []string{ThresholdAQI, ThresholdPollutant, ThresholdRisk}
+
+// RiskLevels are the ML risk categories, lowest first.
+var RiskLevels = []string{"good", "moderate", "poor", "hazardous"}
+
+// defaultMinRisk keeps the old behaviour for risk thresholds: alert on poor and hazardous.
+const defaultMinRisk = "poor"
+
+// riskRank is the position of level in RiskLevels, or -1 if unknown.
+func riskRank(level string) int {
+	return slices.Index(RiskLevels, strings.ToLower(strings.TrimSpace(level)))
+}
+
+// Rule returns the threshold settings with defaults filled in. Rows created
+// before threshold kinds existed use the AQI (US EPA) when they have a
+// threshold and the old poor/hazardous rule otherwise.
+func (n Notification) Rule() Notification {
+	if n.ThresholdKind == "" {
+		if n.Threshold > 0 {
+			n.ThresholdKind = ThresholdAQI
+		} else {
+			n.ThresholdKind = ThresholdRisk
+		}
+	}
+	if n.AQIStandard == "" {
+		n.AQIStandard = airquality.StandardUSEPA
+	}
+	if n.ThresholdKind == ThresholdRisk && n.MinRisk == "" {
+		n.MinRisk = defaultMinRisk
+	}
+	return n
+}
+
+// ValidateRule checks that the threshold settings are complete and known.
+func (n Notification) ValidateRule() error {
+	r := n.Rule()
+	switch r.ThresholdKind {
+	case ThresholdAQI:
+		if !slices.Contains(airquality.Standards, r.AQIStandard) {
+			return fmt.Errorf("aqi_standard must be one of %s", strings.Join(airquality.Standards, ", "))
+		}
+		if r.AQIStandard == airquality.StandardEU && (r.Threshold < 1 || r.Threshold > 6) {
+			return fmt.Errorf("threshold must be an EU AQI band between 1 and 6")
+		} else if r.Threshold <= 0 || r.Threshold > 500 {
+			return fmt.Errorf("threshold must be an AQI value between 1 and 500")
+		}
+	case ThresholdPollutant:
+		if _, ok := pollutant(r.Pollutant); !ok {
+			return fmt.Errorf("unknown pollutant %q", r.Pollutant)
+		}
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive concentration (µg/m³)")
+		}
+	case ThresholdRisk:
+		if riskRank(r.MinRisk) < 0 {
+			return fmt.Errorf("min_risk must be one of %s", strings.Join(RiskLevels, ", "))
+		}
+	default:
+		return fmt.Errorf("threshold_kind must be one of %s", strings.Join(ThresholdKinds, ", "))
+	}
+	return nil
+}
+
+func pollutant(feature string) (airquality.Pollutant, bool) {
+	for _, p := range airquality.Pollutants {
+		if p.Feature == feature {
+			return p, true
+		}
+	}
+	return airquality.Pollutant{}, false
+}
+
+// Evaluation is the result of checking a subscription's threshold against one reading.
+type Evaluation struct {
+	Kind     string
+	Value    float64 // measured AQI, concentration or risk rank
+	Limit    float64
+	Exceeded bool
+	// Level orders readings above the threshold: the AQI category, the risk
+	// rank, or for pollutants how many times the limit was reached.
+	Level int
+	AQI   airquality.AQIResult // always computed under the subscription's standard
+}
+
+// Evaluate compares metrics (and the ML risk level for risk thresholds) with n's threshold.
+func (n Notification) Evaluate(metrics airquality.Metrics, riskLevel string) Evaluation {
+	r := n.Rule()
+	ev := Evaluation{Kind: r.ThresholdKind, Limit: float64(r.Threshold)}
+
+	aqi, err := airquality.AQI(r.AQIStandard, metrics)
+	if err != nil {
+		aqi, _ = airquality.AQI(airquality.StandardUSEPA, metrics)
+	}
+	ev.AQI = aqi
+
+	switch r.ThresholdKind {
+	case ThresholdAQI:
+		ev.Value = float64(aqi.Value)
+		ev.Exceeded = aqi.Standard == r.AQIStandard && ev.Value >= ev.Limit
+		ev.Level = aqiLevel(aqi)
+	case ThresholdPollutant:
+		ev.Value = metrics.FeatureMap()[r.Pollutant]
+		ev.Exceeded = ev.Value >= ev.Limit
+		if ev.Limit > 0 {
+			ev.Level = int(ev.Value / ev.Limit)
+		}
+	case ThresholdRisk:
+		// Bilinmeyen risk seviyesi (ML yok/hatalı) asla uyarı üretmez
+		rank := riskRank(riskLevel)
+		ev.Value, ev.Limit = float64(rank), float64(riskRank(r.MinRisk))
+		ev.Exceeded = rank >= 0 && ev.Limit >= 0 && rank >= int(ev.Limit)
+		ev.Level = rank
+	}
+	return ev
+}
+
+// aqiLevel is the category index of an AQI result (0 = good).
+func aqiLevel(aqi airquality.AQIResult) int {
+	if aqi.Standard == airquality.StandardEU {
+		return aqi.Value - 1
+	}
+	for i, upper := range []int{50, 100, 150, 200, 300} {
+		if aqi.Value <= upper {
+			return i
+		}
+	}
+	return 5
+}
+
+// Describe explains the evaluation in locale (one of Locales; anything else
+// falls back to DefaultLocale).
+func (ev Evaluation) Describe(n Notification, locale string) string {
+	r := n.Rule()
+	en := locale == "en"
+	switch ev.Kind {
+	case ThresholdAQI:
+		if en {
+			return fmt.Sprintf("Air quality index (%s) %d, threshold %d", standardLabel(r.AQIStandard), int(ev.Value), r.Threshold)
+		}
+		return fmt.Sprintf("Hava kalitesi indeksi (%s) %d, eşik %d", standardLabel(r.AQIStandard), int(ev.Value), r.Threshold)
+	case ThresholdPollutant:
+		p, _ := pollutant(r.Pollutant)
+		if en {
+			return fmt.Sprintf("%s %.1f µg/m³, threshold %d µg/m³", p.Label, ev.Value, r.Threshold)
+		}
+		return fmt.Sprintf("%s %.1f µg/m³, eşik %d µg/m³", p.Label, ev.Value, r.Threshold)
+	case ThresholdRisk:
+		level := "UNKNOWN"
+		if i := int(ev.Value); i >= 0 && i < len(RiskLevels) {
+			level = strings.ToUpper(RiskLevels[i])
+		}
+		if en {
+			return fmt.Sprintf("Risk level %s, threshold %s", level, strings.ToUpper(r.MinRisk))
+		}
+		return fmt.Sprintf("Risk seviyesi %s, eşik %s", level, strings.ToUpper(r.MinRisk))
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package notification
+
+import (
+	"testing"
+
+	"nasa-app/internal/airquality"
+)
+
+func TestEvaluationDescribe(t *testing.T) {
+	aqi := Notification{ThresholdKind: ThresholdAQI, AQIStandard: airquality.StandardEU, Threshold: 4}
+	pm := Notification{ThresholdKind: ThresholdPollutant, Pollutant: "pm2_5", Threshold: 25}
+	risk := Notification{ThresholdKind: ThresholdRisk, MinRisk: "poor"}
+	metrics := airquality.Metrics{PM25: 60}
+
+	tests := []struct {
+		n      Notification
+		risk   string
+		locale string
+		want   string
+	}{
+		{aqi, "", "tr", "Hava kalitesi indeksi (EU) 4, eşik 4"},
+		{aqi, "", "en", "Air quality index (EU) 4, threshold 4"},
+		{pm, "", "tr", "PM2.5 60.0 µg/m³, eşik 25 µg/m³"},
+		{pm, "", "en", "PM2.5 60.0 µg/m³, threshold 25 µg/m³"},
+		{risk, "hazardous", "en", "Risk level HAZARDOUS, threshold POOR"},
+		{risk, "", "tr", "Risk seviyesi UNKNOWN, eşik POOR"},
+		{risk, "hazardous", "de", "Risk seviyesi HAZARDOUS, eşik POOR"}, // bilinmeyen dil: varsayılan
+	}
+	for _, tt := range tests {
+		ev := tt.n.Evaluate(metrics, tt.risk)
+		if got := ev.Describe(tt.n, tt.locale); got != tt.want {
+			t.Errorf("%s %s: Describe = %q, want %q", tt.n.ThresholdKind, tt.locale, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	s := NewTokenSigner("app-secret", time.Hour)
+	token, err := s.Sign(PurposeVerifyEmail, 42, emailBinding("A@Example.com "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, bind, err := s.Verify(PurposeVerifyEmail, token)
+	if err != nil || id != 42 || bind != emailBinding("a@example.com") {
+		t.Errorf("Verify = %d, %q, %v; want 42 bound to a@example.com", id, bind, err)
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	s := NewTokenSigner("app-secret", time.Hour)
+	valid, err := s.Sign(PurposeUnsubscribe, 42, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, _ := NewTokenSigner("app-secret", -time.Minute).Sign(PurposeUnsubscribe, 42, "")
+	otherKey, _ := NewTokenSigner("other-secret", time.Hour).Sign(PurposeUnsubscribe, 42, "")
+	// Oturum jetonları ham secret ile imzalanır; bağlantı anahtarıyla doğrulanmamalı
+	session, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, linkClaims{
+		Purpose:          PurposeUnsubscribe,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "42", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("app-secret"))
+	noExpiry, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, linkClaims{
+		Purpose:          PurposeUnsubscribe,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "42"},
+	}).SignedString(s.key)
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, linkClaims{
+		Purpose:          PurposeUnsubscribe,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "42", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	// İmza aynı kalır, abonelik numarası değiştirilir
+	parts := strings.Split(valid, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	payload = []byte(strings.Replace(string(payload), `"sub":"42"`, `"sub":"43"`, 1))
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+	}{
+		{"wrong purpose", PurposeVerifyEmail, valid},
+		{"expired", PurposeUnsubscribe, expired},
+		{"other signing key", PurposeUnsubscribe, otherKey},
+		{"raw app secret", PurposeUnsubscribe, session},
+		{"no expiry", PurposeUnsubscribe, noExpiry},
+		{"alg none", PurposeUnsubscribe, unsigned},
+		{"tampered payload", PurposeUnsubscribe, tampered},
+		{"truncated", PurposeUnsubscribe, valid[:len(valid)-4]},
+		{"empty", PurposeUnsubscribe, ""},
+	}
+	for _, tt := range tests {
+		if id, _, err := s.Verify(tt.purpose, tt.token); !errors.Is(err, ErrInvalidToken) || id != 0 {
+			t.Errorf("%s: Verify = %d, %v; want ErrInvalidToken", tt.name, id, err)
+		}
+	}
+
+	if id, _, err := s.Verify(PurposeUnsubscribe, valid); err != nil || id != 42 {
+		t.Errorf("untouched token: Verify = %d, %v", id, err)
+	}
+}
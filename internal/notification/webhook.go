@@ -0,0 +1,267 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Webhook request headers. X-Signature is "sha256=" followed by the hex
+// HMAC-SHA256 of "<X-Timestamp>.<body>" keyed with the subscription's
+// webhook secret; receivers should also reject stale timestamps.
+const (
+	HeaderSignature  = "X-Signature"
+	HeaderTimestamp  = "X-Timestamp"
+	HeaderDeliveryID = "X-Delivery-ID"
+)
+
+// ValidateWebhook checks the webhook URL of subscriptions that use the webhook channel.
+func (n Notification) ValidateWebhook() error {
+	if !n.HasChannel(ChannelWebhook) {
+		return nil
+	}
+	if n.WebhookURL == "" {
+		return errors.New("webhook_url is required for the webhook channel")
+	}
+	u, err := url.Parse(n.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("webhook_url must be an absolute https URL")
+	}
+	if len(n.WebhookURL) > 512 {
+		return errors.New("webhook_url must be at most 512 characters")
+	}
+	// Sunucunun kendi ağına istek attırılmasın
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return errors.New("webhook_url must be a public host")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return errors.New("webhook_url must be a public host")
+	}
+	return nil
+}
+
+// cgnat is the shared address space of RFC 6598 (carrier-grade NAT).
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether ip may be reached on behalf of a user.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !cgnat.Contains(ip)
+}
+
+var errPrivateAddr = errors.New("destination is not a public address")
+
+// NewPublicClient returns a client for URLs users give us (webhooks, push
+// endpoints). The resolved address is checked when dialing, so hostnames
+// pointing at internal addresses are refused too, and redirects are not
+// followed: a 3xx response is returned as is.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(ap.Addr()) {
+				return fmt.Errorf("dial %s: %w", address, errPrivateAddr)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext, // proxy yok: adres kontrolü atlanmasın
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newWebhookSecret returns a random signing secret for a subscription.
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SignWebhook returns the X-Signature value for body sent at timestamp.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookPayload is the JSON body of a webhook delivery.
+type webhookPayload struct {
+	Event        string              `json:"event"` // alert, all_clear or deferred
+	DeliveryID   string              `json:"delivery_id"`
+	SentAt       time.Time           `json:"sent_at"`
+	Subscription webhookSubscription `json:"subscription"`
+	webhookReading
+	Batch []webhookReading `json:"batch,omitempty"` // deferred alerts, oldest first
+}
+
+type webhookSubscription struct {
+	ID        uint    `json:"id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type webhookReading struct {
+	Kind      string             `json:"kind"`
+	At        time.Time          `json:"at"`
+	RiskLevel string             `json:"risk_level"`
+	Hazardous bool               `json:"hazardous"`
+	Threshold webhookThreshold   `json:"threshold"`
+	AQI       int                `json:"aqi"`
+	Category  string             `json:"aqi_category"`
+	Standard  string             `json:"aqi_standard"`
+	Metrics   map[string]float64 `json:"metrics"`
+	Drivers   []string           `json:"drivers,omitempty"`
+	Episode   *Episode           `json:"episode,omitempty"`
+}
+
+type webhookThreshold struct {
+	Kind     string  `json:"kind"`
+	Value    float64 `json:"value"`
+	Limit    float64 `json:"limit"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+func newWebhookReading(a Alert) webhookReading {
+	return webhookReading{
+		Kind:      a.Kind,
+		At:        a.At,
+		RiskLevel: a.RiskLevel,
+		Hazardous: a.Hazardous(),
+		Threshold: webhookThreshold{
+			Kind:     a.Evaluation.Kind,
+			Value:    a.Evaluation.Value,
+			Limit:    a.Evaluation.Limit,
+			Exceeded: a.Evaluation.Exceeded,
+		},
+		AQI:      a.Evaluation.AQI.Value,
+		Category: a.Evaluation.AQI.Category,
+		Standard: a.Evaluation.AQI.Standard,
+		Metrics:  a.Metrics.FeatureMap(),
+		Drivers:  a.Drivers,
+		Episode:  a.Episode,
+	}
+}
+
+// WebhookSender POSTs alerts to subscription webhooks. Network errors, 429
+// and 5xx responses are retried with exponential backoff under the same
+// delivery ID so receivers can drop duplicates.
+type WebhookSender struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhookSender creates a sender. If client is nil, NewPublicClient with a
+// 10 second timeout is used.
+func NewWebhookSender(client *http.Client, retries int) *WebhookSender {
+	if client == nil {
+		client = NewPublicClient(10 * time.Second)
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &WebhookSender{client: client, retries: retries, backoff: time.Second}
+}
+
+// SendAlert delivers one alert to the subscription's webhook.
+func (s *WebhookSender) SendAlert(alert Alert) error {
+	n := alert.Subscription
+	if n.WebhookURL == "" || n.WebhookSecret == "" {
+		return errors.New("webhook is not configured")
+	}
+
+	payload := webhookPayload{
+		Event:          alert.Kind,
+		DeliveryID:     newDeliveryID(),
+		SentAt:         time.Now().UTC(),
+		Subscription:   webhookSubscription{ID: n.ID, Name: n.Name, Latitude: n.Latitude, Longitude: n.Longitude},
+		webhookReading: newWebhookReading(alert),
+	}
+	for _, a := range alert.Batch {
+		payload.Batch = append(payload.Batch, newWebhookReading(a))
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	wait := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(n, payload.DeliveryID, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.retries {
+			return fmt.Errorf("webhook delivery %s: %w", payload.DeliveryID, err)
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying.
+func (s *WebhookSender) post(n Notification, deliveryID string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create webhook request: %w", err)
+	}
+	// İmza her denemede yeni zaman damgasıyla hesaplanır
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CleanBreathing-Webhook/1")
+	req.Header.Set(HeaderDeliveryID, deliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, SignWebhook(n.WebhookSecret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// newDeliveryID returns a random UUIDv4-formatted delivery ID.
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,360 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PushSubscription is one browser (service worker) registered for Web Push.
+// Push alerts of a user go to all of their browsers.
+type PushSubscription struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	Endpoint  string    `gorm:"size:1024;uniqueIndex;not null" json:"endpoint"`
+	P256dh    string    `gorm:"size:128;not null" json:"-"` // browser public key, base64url
+	Auth      string    `gorm:"size:32;not null" json:"-"`  // auth secret, base64url
+}
+
+func (PushSubscription) TableName() string { return "notification_push_subscriptions" }
+
+// MaxPushPerUser caps the browsers of one user; the oldest are dropped first.
+const MaxPushPerUser = 10
+
+// pushRecordSize is the aes128gcm record size; the whole payload is one record.
+const pushRecordSize = 4096
+
+// decodeBase64URL accepts base64url with or without padding, as browsers differ.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Validate checks the endpoint and keys sent by the browser.
+func (p PushSubscription) Validate() error {
+	u, err := url.Parse(p.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("endpoint must be an absolute https URL")
+	}
+	if len(p.Endpoint) > 1024 {
+		return errors.New("endpoint must be at most 1024 characters")
+	}
+	// Asıl kontrol bağlanırken yapılır (NewPublicClient); bu erken bir ret
+	if host := u.Hostname(); strings.EqualFold(host, "localhost") {
+		return errors.New("endpoint must be a public host")
+	} else if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return errors.New("endpoint must be a public host")
+	}
+	key, err := decodeBase64URL(p.P256dh)
+	if err != nil {
+		return errors.New("keys.p256dh must be base64url")
+	}
+	if _, err := ecdh.P256().NewPublicKey(key); err != nil {
+		return errors.New("keys.p256dh is not a P-256 public key")
+	}
+	auth, err := decodeBase64URL(p.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("keys.auth must be 16 bytes of base64url")
+	}
+	return nil
+}
+
+// VAPIDKeys identify this server to push services (RFC 8292). Public is the
+// applicationServerKey the frontend subscribes with.
+type VAPIDKeys struct {
+	Public  string // base64url uncompressed P-256 point
+	Subject string // mailto: or https: contact for push service operators
+	private *ecdsa.PrivateKey
+}
+
+// ParseVAPIDKeys reads a base64url key pair as produced by GenerateVAPIDKeys
+// (and by the common web-push tools).
+func ParseVAPIDKeys(public, private, subject string) (*VAPIDKeys, error) {
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, errors.New("VAPID subject must be a mailto: or https: URL")
+	}
+	raw, err := decodeBase64URL(private)
+	if err != nil {
+		return nil, fmt.Errorf("decode VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	if given, err := decodeBase64URL(public); err != nil || !bytes.Equal(given, pub) {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+
+	// JWT imzası için ecdsa anahtarı gerekir
+	priv := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:65]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return &VAPIDKeys{Public: base64.RawURLEncoding.EncodeToString(pub), Subject: subject, private: priv}, nil
+}
+
+// GenerateVAPIDKeys returns a new base64url key pair.
+func GenerateVAPIDKeys() (public, private string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// authorization returns the VAPID Authorization header for a push endpoint.
+func (k *VAPIDKeys) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": k.Subject,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(k.private)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + k.Public, nil
+}
+
+// encryptPush encrypts plaintext for one browser (RFC 8291, aes128gcm
+// content coding of RFC 8188) with a fresh server key pair and salt.
+func encryptPush(sub PushSubscription, plaintext []byte) ([]byte, error) {
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptPushWith(sub, plaintext, asKey, salt)
+}
+
+// encryptPushWith is encryptPush with a given server key and salt.
+func encryptPushWith(sub PushSubscription, plaintext []byte, asKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaPublic, err := decodeBase64URL(sub.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeBase64URL(sub.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext)+1+16 > pushRecordSize {
+		return nil, fmt.Errorf("push payload too large: %d bytes", len(plaintext))
+	}
+
+	asPublic := asKey.PublicKey().Bytes()
+	secret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public)
+	ikm, err := hkdf.Key(sha256.New, secret, authSecret, "WebPush: info\x00"+string(uaPublic)+string(asPublic), 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Başlık: salt | rs | idlen | keyid (sunucu açık anahtarı)
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	record := append(append([]byte{}, plaintext...), 0x02) // last record delimiter, no padding
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// pushPayload is the JSON the service worker receives in its push event.
+type pushPayload struct {
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	Tag            string `json:"tag"` // replaces older notifications of the same subscription
+	Kind           string `json:"kind"`
+	SubscriptionID uint   `json:"subscription_id"`
+	RiskLevel      string `json:"risk_level"`
+	AQI            int    `json:"aqi"`
+	Hazardous      bool   `json:"hazardous"`
+	Timestamp      int64  `json:"timestamp"` // ms, for Notification.timestamp
+}
+
+// PushSender delivers alerts to every browser of the subscription owner.
+// Browsers the push service reports as gone (404/410) are removed.
+type PushSender struct {
+	keys   *VAPIDKeys
+	repo   *Repository
+	client *http.Client
+	ttl    time.Duration
+}
+
+// NewPushSender creates a sender. If client is nil, NewPublicClient with a
+// 10 second timeout is used, since endpoints come from browsers.
+func NewPushSender(keys *VAPIDKeys, repo *Repository, client *http.Client) *PushSender {
+	if client == nil {
+		client = NewPublicClient(10 * time.Second)
+	}
+	return &PushSender{keys: keys, repo: repo, client: client, ttl: 4 * time.Hour}
+}
+
+// PublicKey is the applicationServerKey browsers subscribe with.
+func (s *PushSender) PublicKey() string {
+	return s.keys.Public
+}
+
+// SendAlert pushes one alert. It fails only if no browser received it.
+func (s *PushSender) SendAlert(alert Alert) error {
+	n := alert.Subscription
+	subs, err := s.repo.PushSubscriptions(n.UserID)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		log.Printf("subscription %d uses push but user %d has no registered browser", n.ID, n.UserID)
+		return nil
+	}
+
+	data := alertData(alert)
+	title, body, err := renderShort(n.EmailLocale(), alert.Kind, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(pushPayload{
+		Title:          title,
+		Body:           body,
+		Tag:            "aq-" + strconv.FormatUint(uint64(n.ID), 10),
+		Kind:           alert.Kind,
+		SubscriptionID: n.ID,
+		RiskLevel:      data.RiskLevel,
+		AQI:            alert.Evaluation.AQI.Value,
+		Hazardous:      alert.Hazardous(),
+		Timestamp:      alert.At.UnixMilli(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode push payload: %w", err)
+	}
+
+	urgency := "normal"
+	if alert.Hazardous() {
+		urgency = "high"
+	}
+
+	var errs []error
+	delivered := false
+	for _, sub := range subs {
+		if err := s.push(sub, payload, urgency, "aq-"+strconv.FormatUint(uint64(n.ID), 10)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		for _, err := range errs {
+			log.Printf("push for subscription %d: %v", n.ID, err)
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// push sends one encrypted message to one browser.
+func (s *PushSender) push(sub PushSubscription, payload []byte, urgency, topic string) error {
+	body, err := encryptPush(sub, payload)
+	if err != nil {
+		return fmt.Errorf("encrypt push payload: %w", err)
+	}
+	auth, err := s.keys.authorization(sub.Endpoint)
+	if err != nil {
+		return fmt.Errorf("sign VAPID token: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create push request: %w", err)
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(s.ttl.Seconds())))
+	req.Header.Set("Urgency", urgency)
+	req.Header.Set("Topic", topic)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// Tarayıcı aboneliği iptal etmiş
+		if err := s.repo.DeletePushEndpoint(sub.Endpoint); err != nil {
+			log.Printf("delete expired push subscription %d: %v", sub.ID, err)
+		}
+		return fmt.Errorf("push subscription %d expired", sub.ID)
+	default:
+		return fmt.Errorf("push service responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
@@ -0,0 +1,209 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 8291 section 5 example.
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcMessage    = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := decodeBase64URL(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+func TestEncryptPushRFC8291Example(t *testing.T) {
+	asKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcASPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := PushSubscription{P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+
+	got, err := encryptPushWith(sub, []byte(rfcPlaintext), asKey, mustDecode(t, rfcSalt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc := base64.RawURLEncoding.EncodeToString(got); enc != rfcMessage {
+		t.Errorf("message\n got %s\nwant %s", enc, rfcMessage)
+	}
+
+	uaKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain := decryptPush(t, uaKey, mustDecode(t, rfcAuthSecret), mustDecode(t, rfcMessage)); string(plain) != rfcPlaintext {
+		t.Errorf("decrypted %q", plain)
+	}
+}
+
+// decryptPush is the user agent side of RFC 8291, for round trips.
+func decryptPush(t *testing.T, uaKey *ecdh.PrivateKey, authSecret, msg []byte) []byte {
+	t.Helper()
+	if len(msg) < 21 {
+		t.Fatalf("message too short: %d bytes", len(msg))
+	}
+	salt, rs, idlen := msg[:16], binary.BigEndian.Uint32(msg[16:20]), int(msg[20])
+	if rs != pushRecordSize {
+		t.Errorf("record size = %d, want %d", rs, pushRecordSize)
+	}
+	asPublic, ciphertext := msg[21:21+idlen], msg[21+idlen:]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := uaKey.ECDH(asKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := uaKey.PublicKey().Bytes()
+	ikm, err := hkdf.Key(sha256.New, secret, authSecret, "WebPush: info\x00"+string(uaPublic)+string(asPublic), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("open record: %v", err)
+	}
+	end := bytes.LastIndexByte(record, 0x02)
+	if end < 0 || len(bytes.Trim(record[end+1:], "\x00")) != 0 {
+		t.Fatalf("record has no last-record delimiter")
+	}
+	return record[:end]
+}
+
+func TestEncryptPushRoundTrip(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	sub := PushSubscription{
+		Endpoint: "https://fcm.googleapis.com/fcm/send/abc",
+		P256dh:   base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(authSecret),
+	}
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	plaintext := []byte(`{"title":"Hava kalitesi uyarısı","body":"AQI 180"}`)
+	msg, err := encryptPush(sub, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decryptPush(t, uaKey, authSecret, msg); !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted %q, want %q", got, plaintext)
+	}
+
+	if _, err := encryptPush(sub, make([]byte, pushRecordSize)); err == nil {
+		t.Error("oversized payload was encrypted")
+	}
+}
+
+func TestPushSubscriptionValidateRejectsInternalHosts(t *testing.T) {
+	for _, endpoint := range []string{
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://localhost/push",
+		"https://127.0.0.1/push",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.5/push",
+		"https://100.64.1.1/push",
+		"https://[::1]/push",
+	} {
+		sub := PushSubscription{Endpoint: endpoint, P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+		if err := sub.Validate(); err == nil {
+			t.Errorf("Validate(%q) accepted an internal or insecure endpoint", endpoint)
+		}
+	}
+}
+
+func TestVAPIDAuthorization(t *testing.T) {
+	public, private, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParseVAPIDKeys(public, private, "mailto:ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseVAPIDKeys(rfcUAPublic, private, "mailto:ops@example.com"); err == nil {
+		t.Error("mismatched public key was accepted")
+	}
+
+	header, err := keys.authorization("https://updates.push.services.mozilla.com/wpush/v2/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, k, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok || !strings.HasPrefix(header, "vapid t=") || k != public {
+		t.Fatalf("unexpected Authorization header %q", header)
+	}
+
+	raw := mustDecode(t, public)
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[1:33]),
+		Y:     new(big.Int).SetBytes(raw[33:65]),
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return pub, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil || !parsed.Valid {
+		t.Fatalf("VAPID token does not verify: %v", err)
+	}
+	if aud := claims["aud"]; aud != "https://updates.push.services.mozilla.com" {
+		t.Errorf("aud = %v", aud)
+	}
+	if sub := claims["sub"]; sub != "mailto:ops@example.com" {
+		t.Errorf("sub = %v", sub)
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil || exp.After(time.Now().Add(24*time.Hour)) {
+		t.Errorf("exp = %v, must be within 24 hours (RFC 8292)", exp)
+	}
+}
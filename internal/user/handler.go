@@ -1,6 +1,7 @@
 package user
 
 import (
+	"errors"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
@@ -22,3 +23,61 @@ func (h *Handler) Me(c *fiber.Ctx) error {
 	}
 	return c.JSON(user)
 }
+
+// UpdatePhone starts the verification of a new phone number: a code is
+// texted to it and the number is used once ConfirmPhone accepts the code.
+// {"phone": ""} clears the number right away.
+func (h *Handler) UpdatePhone(c *fiber.Ctx) error {
+	uid, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := h.svc.RequestPhone(uid, req.Phone)
+	switch {
+	case errors.Is(err, ErrInvalidPhone):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrPhoneCodeTooSoon):
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrPhoneUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "SMS is not configured"})
+	case err != nil:
+		log.Printf("phone update for user %d failed: %v", uid, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not send the verification code"})
+	}
+	if user.PendingPhone != "" {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Verification code sent", "user": user})
+	}
+	return c.JSON(user)
+}
+
+// ConfirmPhone accepts the texted code ({"code": "123456"}).
+func (h *Handler) ConfirmPhone(c *fiber.Ctx) error {
+	uid, ok := c.Locals("user_id").(uint)
+	if !ok {
+		return fiber.ErrUnauthorized
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	user, err := h.svc.ConfirmPhone(uid, req.Code)
+	switch {
+	case errors.Is(err, ErrPhoneCodeInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrPhoneCodeExhausted):
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+	}
+	return c.JSON(user)
+}
@@ -1,6 +1,10 @@
 package user
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type gormRepo struct{ db *gorm.DB }
 
@@ -18,6 +22,40 @@ func (r *gormRepo) UpdateName(id uint, name string) error {
 		Update("name", name).Error
 }
 
+func (r *gormRepo) ClearPhone(id uint) error {
+	return r.db.Model(&User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"phone": "", "phone_verified_at": nil,
+			"pending_phone": "", "phone_code_hash": "", "phone_code_attempts": 0,
+		}).Error
+}
+
+// SetPhoneCode starts a verification of pending; the current phone stays in use.
+func (r *gormRepo) SetPhoneCode(id uint, pending, codeHash string, sentAt time.Time) error {
+	return r.db.Model(&User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"pending_phone": pending, "phone_code_hash": codeHash,
+			"phone_code_sent_at": sentAt, "phone_code_attempts": 0,
+		}).Error
+}
+
+func (r *gormRepo) AddPhoneCodeAttempt(id uint) error {
+	return r.db.Model(&User{}).
+		Where("id = ?", id).
+		UpdateColumn("phone_code_attempts", gorm.Expr("phone_code_attempts + 1")).Error
+}
+
+func (r *gormRepo) ConfirmPhone(id uint, phone string, at time.Time) error {
+	return r.db.Model(&User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"phone": phone, "phone_verified_at": at,
+			"pending_phone": "", "phone_code_hash": "", "phone_code_attempts": 0,
+		}).Error
+}
+
 func (r *gormRepo) find(q string, arg interface{}) (*User, error) {
 	var u User
 	if err := r.db.Where(q, arg).First(&u).Error; err != nil {
@@ -1,9 +1,15 @@
 package user
 
+import "time"
+
 type Repository interface {
 	Create(u *User) error
 	FindByID(id uint) (*User, error)
 	FindByEmail(e string) (*User, error)
 	FindByGoogleID(gid string) (*User, error)
 	UpdateName(id uint, name string) error // ✅ Method ismi değiştirildi, pic parametresi kaldırıldı
+	ClearPhone(id uint) error
+	SetPhoneCode(id uint, pending, codeHash string, sentAt time.Time) error
+	AddPhoneCodeAttempt(id uint) error
+	ConfirmPhone(id uint, phone string, at time.Time) error
 }
@@ -1,16 +1,58 @@
 package user
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-var ErrNotFound = errors.New("user not found")
+var (
+	ErrNotFound     = errors.New("user not found")
+	ErrInvalidPhone = errors.New("phone must be in E.164 format, e.g. +905551234567")
 
-type Service struct{ repo Repository }
+	ErrPhoneUnavailable   = errors.New("phone verification is not available")
+	ErrPhoneCodeTooSoon   = errors.New("a code was sent recently; try again in a minute")
+	ErrPhoneCodeInvalid   = errors.New("invalid or expired code")
+	ErrPhoneCodeExhausted = errors.New("too many wrong codes; request a new one")
+)
+
+// Phone verification codes: six digits, valid for phoneCodeTTL, at most
+// phoneCodeAttempts tries each and one new code per phoneCodeInterval.
+const (
+	phoneCodeTTL      = 10 * time.Minute
+	phoneCodeInterval = time.Minute
+	phoneCodeAttempts = 5
+)
+
+// e164 is "+", a country code and at most 15 digits in total.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+type Service struct {
+	repo Repository
+
+	// sendCode texts a phone verification code; nil when SMS is not configured
+	sendCode func(phone, code string) error
+	codeKey  []byte
+}
+
+func NewService(r Repository) *Service { return &Service{repo: r} }
 
-func NewService(r Repository) *Service { return &Service{r} }
+// UsePhoneVerification enables phone numbers: codes are sent with send and
+// stored as HMACs keyed with secret.
+func (s *Service) UsePhoneVerification(send func(phone, code string) error, secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("phone-codes"))
+	s.sendCode, s.codeKey = send, mac.Sum(nil)
+}
 
 // FindOrCreate checks by GoogleID; inserts a new row if not found
 func (s *Service) FindOrCreate(gID, email, name, pic string) (*User, error) {
@@ -43,3 +85,97 @@ func (s *Service) FindOrCreate(gID, email, name, pic string) (*User, error) {
 func (s *Service) GetByID(id uint) (*User, error) {
 	return s.repo.FindByID(id)
 }
+
+// NormalizePhone strips common separators and checks the E.164 format.
+// An empty number is valid and clears the phone.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+	if phone == "" {
+		return "", nil
+	}
+	if !e164.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// RequestPhone texts a verification code to phone. The number replaces the
+// current one only after ConfirmPhone; an empty number clears the phone.
+func (s *Service) RequestPhone(id uint, phone string) (*User, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if phone == "" {
+		if err := s.repo.ClearPhone(id); err != nil {
+			return nil, err
+		}
+		return s.repo.FindByID(id)
+	}
+	if s.sendCode == nil {
+		return nil, ErrPhoneUnavailable
+	}
+
+	u, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if u.PhoneCodeSentAt != nil && now.Sub(*u.PhoneCodeSentAt) < phoneCodeInterval {
+		return nil, ErrPhoneCodeTooSoon
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return nil, err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	// Önce kaydet: gönderim başarısız olsa da aralık sınırı işlesin
+	if err := s.repo.SetPhoneCode(id, phone, s.codeHash(id, phone, code), now); err != nil {
+		return nil, err
+	}
+	if err := s.sendCode(phone, code); err != nil {
+		return nil, fmt.Errorf("send phone code: %w", err)
+	}
+	return s.repo.FindByID(id)
+}
+
+// ConfirmPhone checks the code sent by RequestPhone and makes the pending
+// number the user's phone.
+func (s *Service) ConfirmPhone(id uint, code string) (*User, error) {
+	u, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.PendingPhone == "" || u.PhoneCodeHash == "" || u.PhoneCodeSentAt == nil ||
+		time.Since(*u.PhoneCodeSentAt) > phoneCodeTTL {
+		return nil, ErrPhoneCodeInvalid
+	}
+	if u.PhoneCodeAttempts >= phoneCodeAttempts {
+		return nil, ErrPhoneCodeExhausted
+	}
+	want := s.codeHash(id, u.PendingPhone, strings.TrimSpace(code))
+	if !hmac.Equal([]byte(want), []byte(u.PhoneCodeHash)) {
+		if err := s.repo.AddPhoneCodeAttempt(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrPhoneCodeInvalid
+	}
+
+	if err := s.repo.ConfirmPhone(id, u.PendingPhone, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(id)
+}
+
+func (s *Service) codeHash(id uint, phone, code string) string {
+	mac := hmac.New(sha256.New, s.codeKey)
+	fmt.Fprintf(mac, "%d|%s|%s", id, phone, code)
+	return hex.EncodeToString(mac.Sum(nil))
+}
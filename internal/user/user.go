@@ -7,6 +7,22 @@ type User struct {
 	Name      string    `gorm:"size:100" json:"name"`
 	Email     string    `gorm:"uniqueIndex;size:150;not null" json:"email"`
 	GoogleID  string    `gorm:"uniqueIndex;size:100" json:"google_id"`
+	Phone     string    `gorm:"size:16" json:"phone"` // E.164, used for SMS alerts once verified
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Telefon SMS ile gelen kodla doğrulanır; o zamana kadar PendingPhone'da bekler
+	PhoneVerifiedAt   *time.Time `json:"phone_verified_at,omitempty"`
+	PendingPhone      string     `gorm:"size:16" json:"pending_phone,omitempty"`
+	PhoneCodeHash     string     `gorm:"size:64" json:"-"`
+	PhoneCodeSentAt   *time.Time `json:"-"`
+	PhoneCodeAttempts int        `json:"-"`
+}
+
+// VerifiedPhone returns the phone number SMS may be sent to, or "".
+func (u User) VerifiedPhone() string {
+	if u.PhoneVerifiedAt == nil {
+		return ""
+	}
+	return u.Phone
 }